@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 收到 SIGHUP 时重新加载密钥
+func watchKeyReload() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		kr, err := reloadKeys()
+		if err != nil {
+			logger.WithError(err).Error("密钥重新加载失败")
+			continue
+		}
+		logger.WithField("primary", kr.primaryId).Info("密钥已重新加载")
+	}
+}
+
+// 管理接口：重新加载密钥
+func reloadKeysHandler(c *gin.Context) {
+	kr, err := reloadKeys()
+	if err != nil {
+		reqLog(c).WithError(err).Error("密钥重新加载失败")
+		responseJson(c, statusFail, nil, "密钥重新加载失败")
+		return
+	}
+
+	responseJson(c, statusSuccess, map[string]interface{}{
+		"primary": kr.primaryId,
+		"keys":    kr.ids(),
+	}, "密钥已重新加载")
+}
+
+// 管理接口：背景图缓存统计
+func cacheStatsHandler(c *gin.Context) {
+	responseJson(c, statusSuccess, imgCache.Stats(), "")
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"container/list"
+	"image"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// 缓存容量默认值（MB）
+const defaultCacheMemory = 64
+
+// 缩放后背景图的缓存键
+type bacKey struct {
+	src  string
+	w, h int
+}
+
+// 缓存项，文件修改时间变化后视为失效
+type bacEntry struct {
+	key     bacKey
+	img     image.Image
+	modTime time.Time
+	size    int64
+}
+
+// 正在解码的图片，同一张图同时只解码一次
+type bacLoad struct {
+	done chan struct{}
+	img  image.Image
+	err  error
+}
+
+// 解码并缩放后的背景图 LRU 缓存，按图片占用内存限制容量
+type bacCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	ll       *list.List
+	items    map[bacKey]*list.Element
+	loading  map[bacKey]*bacLoad
+
+	hits   uint64
+	misses uint64
+}
+
+// 缓存统计
+type cacheStats struct {
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	Entries  int    `json:"entries"`
+	Bytes    int64  `json:"bytes"`
+	MaxBytes int64  `json:"maxBytes"`
+}
+
+// 全局背景图缓存
+var imgCache *bacCache
+
+// 创建缓存，maxBytes 为内存上限
+func newBacCache(maxBytes int64) *bacCache {
+	return &bacCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[bacKey]*list.Element),
+		loading:  make(map[bacKey]*bacLoad),
+	}
+}
+
+// 获取缩放到 w*h 的背景图，未命中时解码并缩放后放入缓存
+// 返回的图片会被多个请求共用，调用方不能修改
+func (bc *bacCache) Get(src string, w, h int) (img image.Image, err error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return
+	}
+	key := bacKey{src, w, h}
+
+	bc.mu.Lock()
+	if el, ok := bc.items[key]; ok {
+		entry := el.Value.(*bacEntry)
+		if entry.modTime.Equal(info.ModTime()) {
+			bc.ll.MoveToFront(el)
+			bc.mu.Unlock()
+			atomic.AddUint64(&bc.hits, 1)
+			return entry.img, nil
+		}
+		// 文件已更新
+		bc.removeElement(el)
+	}
+	atomic.AddUint64(&bc.misses, 1)
+
+	// 其他请求正在解码同一张图，等待其结果
+	if ld, ok := bc.loading[key]; ok {
+		bc.mu.Unlock()
+		<-ld.done
+		return ld.img, ld.err
+	}
+	ld := &bacLoad{done: make(chan struct{})}
+	bc.loading[key] = ld
+	bc.mu.Unlock()
+
+	img, err = getImg(src)
+	if err == nil {
+		img = imaging.Resize(img, w, h, imaging.Lanczos)
+	}
+	ld.img, ld.err = img, err
+	close(ld.done)
+
+	bc.mu.Lock()
+	delete(bc.loading, key)
+	if err == nil {
+		bc.add(&bacEntry{key: key, img: img, modTime: info.ModTime(), size: imageBytes(img)})
+	}
+	bc.mu.Unlock()
+	return
+}
+
+// 加入缓存并淘汰最久未使用的项，超过容量的单张图不缓存
+func (bc *bacCache) add(entry *bacEntry) {
+	if entry.size > bc.maxBytes {
+		return
+	}
+	if el, ok := bc.items[entry.key]; ok {
+		bc.removeElement(el)
+	}
+	bc.items[entry.key] = bc.ll.PushFront(entry)
+	bc.bytes += entry.size
+
+	for bc.bytes > bc.maxBytes {
+		bc.removeElement(bc.ll.Back())
+	}
+}
+
+// 移除缓存项
+func (bc *bacCache) removeElement(el *list.Element) {
+	entry := bc.ll.Remove(el).(*bacEntry)
+	delete(bc.items, entry.key)
+	bc.bytes -= entry.size
+}
+
+// 移除某张背景图的所有缓存
+func (bc *bacCache) Forget(src string) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	for key, el := range bc.items {
+		if key.src == src {
+			bc.removeElement(el)
+		}
+	}
+}
+
+// 缓存统计
+func (bc *bacCache) Stats() cacheStats {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	return cacheStats{
+		Hits:     atomic.LoadUint64(&bc.hits),
+		Misses:   atomic.LoadUint64(&bc.misses),
+		Entries:  bc.ll.Len(),
+		Bytes:    bc.bytes,
+		MaxBytes: bc.maxBytes,
+	}
+}
+
+// 图片像素数据占用的内存
+func imageBytes(img image.Image) int64 {
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return int64(len(nrgba.Pix))
+	}
+	b := img.Bounds()
+	return int64(b.Dx()) * int64(b.Dy()) * 4
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"image"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 验证码类型
+const (
+	typeSlider = "slider" // 滑动拼图
+	typeRotate = "rotate" // 旋转摆正
+	typeClick  = "click"  // 按顺序点选文字
+	typeText   = "text"   // 扭曲字符
+)
+
+// 各类型验证码的答案校验结果
+type checkResult struct {
+	pass bool    // 答案是否正确
+	risk float64 // 风险分值（0~1）
+	bot  bool    // 命中明显的机器特征，验证码直接作废
+}
+
+// 验证码类型的实现，共用 getCode、图片接口、签名以及 verify
+type challengeType struct {
+	// getCode 中生成答案，写入签名
+	setup func(slider *SliderInfo)
+	// /slider 返回的图片，没有时为 nil
+	piece func(img image.Image, slider SliderInfo) image.Image
+	// /sliderBac 返回的图片
+	background func(img image.Image, slider SliderInfo) image.Image
+	// verify 中校验答案，返回的错误信息直接返回给前端
+	check func(c *gin.Context, slider SliderInfo) (checkResult, error)
+	// 不使用背景图库中的图片
+	noImage bool
+}
+
+// 已支持的验证码类型
+var challengeTypes = map[string]challengeType{
+	typeSlider: {
+		setup:      setupSlider,
+		piece:      sliderPiece,
+		background: sliderBackground,
+		check:      checkSlider,
+	},
+	typeRotate: {
+		setup:      setupRotate,
+		background: rotateImage,
+		check:      checkRotate,
+	},
+	typeClick: {
+		setup:      setupClick,
+		piece:      clickTargets,
+		background: clickBackground,
+		check:      checkClick,
+	},
+	typeText: {
+		setup:      setupText,
+		background: textImage,
+		check:      checkText,
+		noImage:    true,
+	},
+}
+
+// 验证码类型，旧签名中没有类型的按滑动拼图处理
+func (s SliderInfo) challengeType() string {
+	if len(s.Type) == 0 {
+		return typeSlider
+	}
+	return s.Type
+}
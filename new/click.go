@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/freetype/truetype"
+)
+
+// 默认候选字符
+const defaultClickChars = "验证码安全登录注册账号密码手机邮箱提交确认网络服务中心天地人和山水风云日月星光春夏秋冬东南西北"
+
+// 内置字体不含中文时使用的候选字符，去掉了容易混淆的字母
+const fallbackClickChars = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// 点选验证码的字体与候选字符，启动时加载
+var (
+	clickFont  *truetype.Font
+	clickChars []rune
+)
+
+// 点选字颜色
+var clickPalette = []color.NRGBA{
+	{0xe5, 0x39, 0x35, 0xff},
+	{0xfb, 0x8c, 0x00, 0xff},
+	{0xfd, 0xd8, 0x35, 0xff},
+	{0x43, 0xa0, 0x47, 0xff},
+	{0x1e, 0x88, 0xe5, 0xff},
+	{0x8e, 0x24, 0xaa, 0xff},
+	{0xff, 0xff, 0xff, 0xff},
+}
+
+// 背景图上的一个字
+type Glyph struct {
+	Char  string `json:"Char"`
+	X     int    `json:"X"`
+	Y     int    `json:"Y"`
+	Size  int    `json:"Size"`
+	Angle int    `json:"Angle"`
+	Color int    `json:"Color"` // clickPalette 下标
+}
+
+// 加载点选字体，过滤出字体中有的候选字符
+func loadClickFont() error {
+	f, err := loadFont(conf.Click.Font)
+	if err != nil {
+		return err
+	}
+
+	chars := conf.Click.Chars
+	if len(chars) == 0 {
+		chars = defaultClickChars
+	}
+	runes := glyphsInFont(f, chars)
+
+	// 明确配置了字体或候选字符时，字体必须包含全部候选字符
+	if len(conf.Click.Font) > 0 || len(conf.Click.Chars) > 0 {
+		if missing := missingGlyphs(f, chars); len(missing) > 0 {
+			return fmt.Errorf("字体中没有以下候选字符的字形: %s", missing)
+		}
+	} else if len(runes) < conf.Click.Count+conf.Click.Distractors {
+		logger.WithField("chars", fallbackClickChars).Warn("内置字体不含中文，点选验证改用英文字母，如需中文请配置 [Click] font")
+		runes = glyphsInFont(f, fallbackClickChars)
+	}
+	if len(runes) < conf.Click.Count+conf.Click.Distractors {
+		return errors.New("字体中可用字符不足")
+	}
+
+	clickFont = f
+	clickChars = runes
+	return nil
+}
+
+// 随机选字并放置在背景图上，Glyphs 前 Targets 个为需要依次点击的字
+func setupClick(slider *SliderInfo) {
+	n := conf.Click.Count + conf.Click.Distractors
+	base := slider.BacH / 5
+
+	var placed []image.Rectangle
+	for _, i := range rand.Perm(len(clickChars))[:n] {
+		size := base * (90 + rand.Intn(21)) / 100
+		g := Glyph{
+			Char:  string(clickChars[i]),
+			Size:  size,
+			Angle: rand.Intn(91) - 45,
+			Color: rand.Intn(len(clickPalette)),
+		}
+
+		// 字与字之间不重叠，放不下时允许重叠
+		for tries := 0; tries < 30; tries++ {
+			g.X = rand.Intn(slider.BacW - size)
+			g.Y = rand.Intn(slider.BacH - size)
+			if !overlapsAny(image.Rect(g.X, g.Y, g.X+size, g.Y+size), placed) {
+				break
+			}
+		}
+		placed = append(placed, image.Rect(g.X, g.Y, g.X+size, g.Y+size).Inset(-4))
+		slider.Glyphs = append(slider.Glyphs, g)
+	}
+	slider.Targets = conf.Click.Count
+}
+
+// 与已放置的区域是否重叠
+func overlapsAny(r image.Rectangle, rects []image.Rectangle) bool {
+	for _, o := range rects {
+		if r.Overlaps(o) {
+			return true
+		}
+	}
+	return false
+}
+
+// 把字画到背景图上
+func clickBackground(img image.Image, slider SliderInfo) image.Image {
+	dist := image.NewRGBA(image.Rect(0, 0, slider.BacW, slider.BacH))
+	draw.Draw(dist, dist.Bounds(), img, image.ZP, draw.Src)
+
+	for _, g := range slider.Glyphs {
+		r := []rune(g.Char)[0]
+		glyph := renderGlyph(clickFont, r, g.Size, clickPalette[g.Color%len(clickPalette)], float64(g.Angle))
+
+		// 旋转后图片变大，以字的中心对齐
+		b := glyph.Bounds()
+		cx, cy := g.X+g.Size/2, g.Y+g.Size/2
+		rect := image.Rect(cx-b.Dx()/2, cy-b.Dy()/2, cx-b.Dx()/2+b.Dx(), cy-b.Dy()/2+b.Dy())
+		draw.Draw(dist, rect, glyph, b.Min, draw.Over)
+	}
+	return dist
+}
+
+// 需要依次点击的字，以图片返回，不返回文本
+func clickTargets(img image.Image, slider SliderInfo) image.Image {
+	size := slider.BacH / 5
+	pad := size / 4
+	n := slider.Targets
+
+	rgba := image.NewRGBA(image.Rect(0, 0, n*size+(n+1)*pad, size+2*pad))
+	draw.Draw(rgba, rgba.Bounds(), image.NewUniform(color.White), image.ZP, draw.Src)
+
+	ink := color.NRGBA{0x33, 0x33, 0x33, 0xff}
+	for i, g := range slider.Glyphs[:n] {
+		glyph := renderGlyph(clickFont, []rune(g.Char)[0], size, ink, 0)
+		rect := image.Rect(pad+i*(size+pad), pad, pad+i*(size+pad)+size, pad+size)
+		draw.Draw(rgba, rect, glyph, image.ZP, draw.Over)
+	}
+	return rgba
+}
+
+// 校验点击位置，points 格式为 [[x,y],...]，需按顺序落在目标字上
+func checkClick(c *gin.Context, slider SliderInfo) (res checkResult, err error) {
+	var points [][]int
+	if err = json.Unmarshal([]byte(c.PostForm("points")), &points); err != nil {
+		err = errors.New("请求参数points不正确")
+		return
+	}
+	if len(points) != slider.Targets {
+		return
+	}
+
+	tol := slider.site().tolerance
+	res.pass = true
+	for i, p := range points {
+		if len(p) != 2 {
+			err = errors.New("请求参数points不正确")
+			return
+		}
+		g := slider.Glyphs[i]
+		box := image.Rect(g.X-tol, g.Y-tol, g.X+g.Size+tol, g.Y+g.Size+tol)
+		if !(image.Point{p[0], p[1]}.In(box)) {
+			res.pass = false
+		}
+	}
+	return
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestLoadClickFont(t *testing.T) {
+	old := conf.Click
+	t.Cleanup(func() { conf.Click = old })
+
+	tests := []struct {
+		name  string
+		chars string
+		want  string // 为空时应加载失败
+	}{
+		{"builtin falls back to latin", "", fallbackClickChars},
+		{"configured latin chars", "ABCDEFG", "ABCDEFG"},
+		{"configured chars missing from font", "验证码ABCDE", ""},
+	}
+	for _, tt := range tests {
+		conf.Click.Font = ""
+		conf.Click.Chars = tt.chars
+		conf.Click.Count = defaultClicks
+		conf.Click.Distractors = defaultDistract
+
+		err := loadClickFont()
+		if len(tt.want) == 0 {
+			if err == nil {
+				t.Errorf("%s: loaded chars %q, want error", tt.name, string(clickChars))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(clickChars) != tt.want {
+			t.Errorf("%s: chars = %q, want %q", tt.name, string(clickChars), tt.want)
+		}
+	}
+}
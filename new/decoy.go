@@ -0,0 +1,85 @@
+package main
+
+import (
+	"image"
+	"math/rand"
+)
+
+// 最多干扰缺口数
+const maxDecoys = 3
+
+// 干扰缺口，形状与阴影和真实缺口略有不同
+type Decoy struct {
+	X    int   `json:"X"`
+	Y    int   `json:"Y"`
+	W    int   `json:"W"`    // 边长
+	Seed int64 `json:"Seed"` // 形状随机种子
+}
+
+// 随机放置干扰缺口，不与真实缺口及彼此重叠，横向与真实缺口至少相隔一个滑块宽度
+func placeDecoys(n, width, hight, sliderW, dx, dy int) []Decoy {
+	if n > maxDecoys {
+		n = maxDecoys
+	}
+	taken := []image.Rectangle{image.Rect(dx, dy, dx+sliderW, dy+sliderW)}
+
+	var decoys []Decoy
+	for tries := 0; len(decoys) < n && tries < 50; tries++ {
+		// 边长在真实滑块的 85%~110% 之间
+		w := sliderW * (85 + rand.Intn(26)) / 100
+		if width-w <= 0 || hight-w <= 0 {
+			break
+		}
+		d := Decoy{X: rand.Intn(width - w), Y: rand.Intn(hight - w), W: w, Seed: rand.Int63()}
+		if abs(d.X-dx) < sliderW {
+			continue
+		}
+
+		rect := image.Rect(d.X, d.Y, d.X+w, d.Y+w)
+		overlap := false
+		for _, r := range taken {
+			if rect.Overlaps(r.Inset(-4)) {
+				overlap = true
+				break
+			}
+		}
+		if overlap {
+			continue
+		}
+		taken = append(taken, rect)
+		decoys = append(decoys, d)
+	}
+	return decoys
+}
+
+// 干扰缺口的绘制参数：比真实缺口浅一些、阴影更窄
+func decoyStyle(s renderStyle) renderStyle {
+	s.hole.A = uint8(int(s.hole.A) * 3 / 4)
+	s.shadow.A = uint8(int(s.shadow.A) * 4 / 5)
+	s.bevel.A = uint8(int(s.bevel.A) * 3 / 5)
+	if s.shadowWidth > 1 {
+		s.shadowWidth--
+	}
+	return s
+}
+
+// 在背景图上绘制干扰缺口
+func drawDecoys(dst *image.RGBA, slider SliderInfo) {
+	st := decoyStyle(style)
+	shape := getShape(slider.Shape)
+	for _, d := range slider.Decoys {
+		mask := shape.Mask(d.W, d.W, rand.New(rand.NewSource(d.Seed)))
+		drawHole(dst, image.Rect(d.X, d.Y, d.X+d.W, d.Y+d.W), mask, st)
+	}
+}
+
+// 判断落点是否在干扰缺口上，y 小于0表示未提交
+func hitDecoy(slider SliderInfo, x, y int) bool {
+	tol := slider.site().tolerance
+	for _, d := range slider.Decoys {
+		if abs(x-d.X) <= tol && (y < 0 || abs(y-d.Y) <= tol) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 图片输出格式
+type imageEncoder struct {
+	contentType string
+	// quality 为 1~100，不支持时忽略
+	encode func(w io.Writer, img image.Image, quality int) error
+	// 是否支持透明
+	alpha bool
+}
+
+// 已支持的输出格式，webp 需要 cgo，见 encode_webp.go
+var encoders = map[string]imageEncoder{
+	"png": {
+		contentType: "image/png",
+		encode: func(w io.Writer, img image.Image, quality int) error {
+			return png.Encode(w, img)
+		},
+		alpha: true,
+	},
+	"jpeg": {
+		contentType: "image/jpeg",
+		encode: func(w io.Writer, img image.Image, quality int) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+		},
+	},
+}
+
+// 输出格式默认值
+const (
+	defaultBacFormat   = "jpeg"
+	defaultPieceFormat = "png"
+	defaultQuality     = 80
+)
+
+// 按查询参数 format/quality、Accept 请求头与配置选择格式并输出图片
+// 图片含透明像素而所选格式不支持透明时改用 png
+func writeImage(c *gin.Context, img image.Image, format string) {
+	name := negotiateFormat(c, format)
+	if o, ok := img.(interface{ Opaque() bool }); ok && !encoders[name].alpha && !o.Opaque() {
+		name = "png"
+	}
+	enc := encoders[name]
+
+	quality := conf.Output.Quality
+	if q, err := strconv.Atoi(c.Query("quality")); err == nil && q >= 1 && q <= 100 {
+		quality = q
+	}
+
+	c.Header("Content-Type", enc.contentType)
+	c.Writer.Header().Add("Vary", "Accept")
+	enc.encode(c.Writer, img, quality)
+}
+
+// 选择输出格式：查询参数 > 配置的默认格式（Accept 允许时）> Accept 中第一个支持的格式
+func negotiateFormat(c *gin.Context, format string) string {
+	if q := normalizeFormat(c.Query("format")); len(q) > 0 {
+		if _, ok := encoders[q]; ok {
+			return q
+		}
+	}
+	if _, ok := encoders[format]; !ok {
+		format = "png"
+	}
+
+	accept := c.GetHeader("Accept")
+	if len(accept) == 0 {
+		return format
+	}
+
+	var accepted []string
+	for _, part := range strings.Split(accept, ",") {
+		mime := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if mime == "*/*" || mime == "image/*" || mime == encoders[format].contentType {
+			return format
+		}
+		accepted = append(accepted, mime)
+	}
+	for _, mime := range accepted {
+		if name := normalizeFormat(strings.TrimPrefix(mime, "image/")); len(name) > 0 {
+			if _, ok := encoders[name]; ok {
+				return name
+			}
+		}
+	}
+	return format
+}
+
+// 统一格式名称
+func normalizeFormat(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "jpg" {
+		return "jpeg"
+	}
+	return name
+}
@@ -0,0 +1,22 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"image"
+	"io"
+
+	"github.com/chai2010/webp"
+)
+
+// webp 使用无损压缩，依赖 cgo，CGO_ENABLED=0 编译时不支持 webp 输出
+func init() {
+	encoders["webp"] = imageEncoder{
+		contentType: "image/webp",
+		encode: func(w io.Writer, img image.Image, quality int) error {
+			return webp.Encode(w, img, &webp.Options{Lossless: true})
+		},
+		alpha: true,
+	}
+}
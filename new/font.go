@@ -0,0 +1,79 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+)
+
+// 加载字体文件，未配置时使用内置的 Go 字体（只含拉丁字母）
+func loadFont(path string) (*truetype.Font, error) {
+	if len(path) == 0 {
+		return freetype.ParseFont(gobold.TTF)
+	}
+	b, err := ioutil.ReadFile(resolvePath(path))
+	if err != nil {
+		return nil, err
+	}
+	return freetype.ParseFont(b)
+}
+
+// 过滤出字体中有字形的字符
+func glyphsInFont(f *truetype.Font, chars string) []rune {
+	var runes []rune
+	for _, r := range chars {
+		if f.Index(r) != 0 {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+// 字体中没有字形的字符
+func missingGlyphs(f *truetype.Font, chars string) string {
+	var missing []rune
+	for _, r := range chars {
+		if f.Index(r) == 0 {
+			missing = append(missing, r)
+		}
+	}
+	return string(missing)
+}
+
+// 在 size×size 的透明图片中居中绘制单个字符，再逆时针旋转 angle 度
+func renderGlyph(f *truetype.Font, r rune, size int, col color.Color, angle float64) image.Image {
+	rgba := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	fontSize := float64(size) * 0.8
+	face := truetype.NewFace(f, &truetype.Options{Size: fontSize})
+	defer face.Close()
+
+	// 按字形宽度与字体上下高度居中
+	advance := font.MeasureString(face, string(r)).Round()
+	metrics := face.Metrics()
+	ascent, descent := metrics.Ascent.Round(), metrics.Descent.Round()
+	x := (size - advance) / 2
+	y := (size-(ascent+descent))/2 + ascent
+
+	ctx := freetype.NewContext()
+	ctx.SetDPI(72)
+	ctx.SetFont(f)
+	ctx.SetFontSize(fontSize)
+	ctx.SetClip(rgba.Bounds())
+	ctx.SetDst(rgba)
+	ctx.SetSrc(image.NewUniform(col))
+	ctx.SetHinting(font.HintingNone)
+	ctx.DrawString(string(r), freetype.Pt(x, y))
+
+	if math.Mod(angle, 360) == 0 {
+		return rgba
+	}
+	return imaging.Rotate(rgba, angle, color.Transparent)
+}
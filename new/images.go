@@ -0,0 +1,314 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/gin-gonic/gin"
+)
+
+// 背景图管理默认值
+const (
+	defaultMaxWidth  = 800 // 上传后缩小到的最大宽度
+	defaultMaxUpload = 10  // 上传文件大小上限（MB）
+
+	maxUploadSide = 8000 // 上传图片的最大宽高（像素），解码前按图片头校验，防止小文件解码出超大图片
+)
+
+// 停用的背景图加上此后缀，图库扫描时跳过
+const disabledSuffix = ".disabled"
+
+// 允许管理的文件名，防止路径穿越
+var imageNameReg = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// 背景图列表项
+type imageItem struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Disabled bool   `json:"disabled"`
+	ModTime  int64  `json:"modTime"`
+}
+
+// 管理接口：背景图列表，含已停用的图片
+func listImagesHandler(c *gin.Context) {
+	lib, ok := siteLibrary(c)
+	if !ok {
+		responseJson(c, statusFail, nil, "站点不存在")
+		return
+	}
+	files, err := ioutil.ReadDir(lib.dir)
+	if err != nil {
+		reqLog(c).WithError(err).Error("读取背景图目录失败")
+		responseJson(c, statusFail, nil, "读取背景图目录失败")
+		return
+	}
+
+	items := []imageItem{}
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), disabledSuffix)
+		if f.IsDir() || !imageExtReg.MatchString(name) {
+			continue
+		}
+		item := imageItem{
+			Name:     name,
+			Size:     f.Size(),
+			Disabled: name != f.Name(),
+			ModTime:  f.ModTime().Unix(),
+		}
+		if cfg, err := getImgConfig(filepath.Join(lib.dir, f.Name())); err == nil {
+			item.Width, item.Height = cfg.Width, cfg.Height
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+
+	responseJson(c, statusSuccess, items, "")
+}
+
+// 管理接口：上传背景图
+// 校验格式与尺寸，居中裁剪为 2:1 并统一保存为 png
+func uploadImageHandler(c *gin.Context) {
+	lib, ok := siteLibrary(c)
+	if !ok {
+		responseJson(c, statusFail, nil, "站点不存在")
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(conf.Library.MaxUpload)<<20)
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		responseJson(c, statusFail, nil, "请上传图片文件 file")
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		responseJson(c, statusFail, nil, "图片读取失败")
+		return
+	}
+	defer f.Close()
+
+	// 先读取图片头中的尺寸，过大的图片不解码
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		responseJson(c, statusFail, nil, "不支持的图片格式，支持 png、jpeg、gif、webp")
+		return
+	}
+	if cfg.Width > maxUploadSide || cfg.Height > maxUploadSide {
+		responseJson(c, statusFail, nil, fmt.Sprintf("图片尺寸过大，最大 %dx%d", maxUploadSide, maxUploadSide))
+		return
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		responseJson(c, statusFail, nil, "图片读取失败")
+		return
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		responseJson(c, statusFail, nil, "不支持的图片格式，支持 png、jpeg、gif、webp")
+		return
+	}
+	img, err = normalizeImage(img)
+	if err != nil {
+		responseJson(c, statusFail, nil, err.Error())
+		return
+	}
+
+	id, err := newChallengeID()
+	if err != nil {
+		responseJson(c, statusFail, nil, "数据错误")
+		return
+	}
+	name := id[:16] + ".png"
+	if err = writeImageFile(filepath.Join(lib.dir, name), img); err != nil {
+		reqLog(c).WithError(err).WithField("image", name).Error("背景图保存失败")
+		responseJson(c, statusFail, nil, "背景图保存失败")
+		return
+	}
+	refreshLibrary(lib)
+
+	item := imageItem{Name: name, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if info, err := os.Stat(filepath.Join(lib.dir, name)); err == nil {
+		item.Size, item.ModTime = info.Size(), info.ModTime().Unix()
+	}
+	responseJson(c, statusSuccess, item, "上传成功")
+}
+
+// 管理接口：预览背景图原图
+func previewImageHandler(c *gin.Context) {
+	lib, ok := siteLibrary(c)
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	file, err := imageFile(lib, c.Param("name"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	img, err := getImg(file)
+	if err != nil {
+		reqLog(c).WithError(err).WithField("image", file).Error("背景图解码失败")
+		responseJson(c, statusFail, nil, "文件查询不到")
+		return
+	}
+	writeImage(c, img, "png")
+}
+
+// 管理接口：停用背景图，文件保留
+func disableImageHandler(c *gin.Context) {
+	setImageEnabled(c, false)
+}
+
+// 管理接口：重新启用背景图
+func enableImageHandler(c *gin.Context) {
+	setImageEnabled(c, true)
+}
+
+// 管理接口：删除背景图
+func deleteImageHandler(c *gin.Context) {
+	lib, ok := siteLibrary(c)
+	if !ok {
+		responseJson(c, statusFail, nil, "站点不存在")
+		return
+	}
+	file, err := imageFile(lib, c.Param("name"))
+	if err != nil {
+		responseJson(c, statusFail, nil, err.Error())
+		return
+	}
+	if err = os.Remove(file); err != nil {
+		reqLog(c).WithError(err).WithField("image", file).Error("背景图删除失败")
+		responseJson(c, statusFail, nil, "删除失败")
+		return
+	}
+	imgCache.Forget(file)
+	refreshLibrary(lib)
+
+	responseJson(c, statusSuccess, nil, "已删除")
+}
+
+// 启用或停用背景图，通过文件名后缀区分
+func setImageEnabled(c *gin.Context, enabled bool) {
+	lib, ok := siteLibrary(c)
+	if !ok {
+		responseJson(c, statusFail, nil, "站点不存在")
+		return
+	}
+	file, err := imageFile(lib, c.Param("name"))
+	if err != nil {
+		responseJson(c, statusFail, nil, err.Error())
+		return
+	}
+
+	name := strings.TrimSuffix(file, disabledSuffix)
+	target := name
+	if !enabled {
+		target = name + disabledSuffix
+	}
+	if file != target {
+		if err = os.Rename(file, target); err != nil {
+			reqLog(c).WithError(err).WithField("image", file).Error("背景图重命名失败")
+			responseJson(c, statusFail, nil, "操作失败")
+			return
+		}
+	}
+	imgCache.Forget(name)
+	refreshLibrary(lib)
+
+	responseJson(c, statusSuccess, nil, "操作成功")
+}
+
+// 管理的背景图库，查询参数 site 指定站点，不传为默认站点
+func siteLibrary(c *gin.Context) (*imageLibrary, bool) {
+	st, ok := getSite(c.Query("site"))
+	if !ok {
+		return nil, false
+	}
+	return st.library, true
+}
+
+// 按名称查找背景图文件，已停用的也能找到
+func imageFile(lib *imageLibrary, name string) (file string, err error) {
+	if !imageNameReg.MatchString(name) || !imageExtReg.MatchString(name) {
+		err = errors.New("图片名称不正确")
+		return
+	}
+	for _, f := range []string{name, name + disabledSuffix} {
+		file = filepath.Join(lib.dir, f)
+		if _, err = os.Stat(file); err == nil {
+			return
+		}
+	}
+	err = errors.New("图片不存在")
+	return
+}
+
+// 校验最小尺寸，居中裁剪为背景图的宽高比，过大的图片缩小
+func normalizeImage(img image.Image) (image.Image, error) {
+	b := img.Bounds()
+	if b.Dx() < bacW || b.Dy() < bacH {
+		return nil, fmt.Errorf("图片尺寸过小，至少需要 %dx%d", bacW, bacH)
+	}
+
+	w, h := b.Dx(), b.Dx()*bacH/bacW
+	if h > b.Dy() {
+		w, h = b.Dy()*bacW/bacH, b.Dy()
+	}
+	dst := imaging.CropCenter(img, w, h)
+
+	if w > conf.Library.MaxWidth {
+		dst = imaging.Resize(dst, conf.Library.MaxWidth, conf.Library.MaxWidth*bacH/bacW, imaging.Lanczos)
+	}
+	return dst, nil
+}
+
+// 先写临时文件再改名，图库轮询不会读到写了一半的图片
+func writeImageFile(file string, img image.Image) error {
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// 读取图片尺寸
+func getImgConfig(file string) (cfg image.Config, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	cfg, _, err = image.DecodeConfig(f)
+	return
+}
+
+// 立即刷新背景图库，不等待下次轮询
+func refreshLibrary(lib *imageLibrary) {
+	if _, err := lib.Refresh(); err != nil {
+		logger.WithError(err).WithField("dir", lib.dir).Error("背景图库刷新失败")
+	}
+}
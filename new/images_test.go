@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 只有文件头的 png，声明的尺寸为 w×h
+func pngHeader(w, h uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], w)
+	binary.BigEndian.PutUint32(ihdr[8:], h)
+	ihdr[12], ihdr[13] = 8, 2 // 8 位 RGB
+
+	b := []byte("\x89PNG\r\n\x1a\n")
+	b = append(b, 0, 0, 0, 13)
+	b = append(b, ihdr...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(ihdr))
+	return append(b, crc...)
+}
+
+// 声明尺寸过大的图片在解码前被拒绝
+func TestUploadImageRejectsHugeDimensions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldSites, oldLibrary := sites, conf.Library
+	t.Cleanup(func() { sites, conf.Library = oldSites, oldLibrary })
+	sites = map[string]*site{defaultSiteKey: {key: defaultSiteKey, library: &imageLibrary{dir: t.TempDir()}}}
+	conf.Library.MaxUpload = defaultMaxUpload
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	fw, err := mw.CreateFormFile("file", "huge.png")
+	mustNil(t, err)
+	fw.Write(pngHeader(50000, 50000))
+	mustNil(t, mw.Close())
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/admin/images", body)
+	c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+	uploadImageHandler(c)
+
+	var res JsonRes
+	mustNil(t, json.Unmarshal(w.Body.Bytes(), &res))
+	if res.Status != statusFail || !strings.Contains(res.Msg, "过大") {
+		t.Fatalf("response = %+v, want image too large", res)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/gcfg.v1"
+)
+
+// 密钥相关环境变量，优先级高于配置文件
+const (
+	envKey        = "SLIDER_KEY"          // AES 密钥
+	envKeyFile    = "SLIDER_KEY_FILE"     // AES 密钥文件
+	envRsaKeyFile = "SLIDER_RSA_KEY_FILE" // RSA 私钥文件
+	envOldKeys    = "SLIDER_OLD_KEYS"     // 仅用于解密的旧密钥，格式 id:key，逗号分隔
+)
+
+// 未配置密钥ID时使用
+const defaultKeyId = "0"
+
+// 密钥环：主密钥用于加密，其余密钥仅用于解密旧签名
+type keyring struct {
+	primaryId string
+	keys      map[string][]byte
+}
+
+var (
+	ring   atomic.Value // 当前密钥环 *keyring，重新加载时整体替换
+	ringMu sync.Mutex   // 防止并发重新加载
+)
+
+// 获取当前密钥环
+func currentKeyring() *keyring {
+	return ring.Load().(*keyring)
+}
+
+// 主密钥
+func (k *keyring) primary() (string, []byte) {
+	return k.primaryId, k.keys[k.primaryId]
+}
+
+// 全部密钥ID，主密钥在前
+func (k *keyring) ids() []string {
+	ids := []string{k.primaryId}
+	for id := range k.keys {
+		if id != k.primaryId {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// 根据配置和环境变量生成密钥环
+func loadKeyring(kc keyConfig) (*keyring, error) {
+	primary, err := loadKey(kc)
+	if err != nil {
+		return nil, err
+	}
+
+	id := kc.Id
+	if len(id) == 0 {
+		id = defaultKeyId
+	}
+	if len(id) > 255 {
+		return nil, errors.New("密钥ID过长: " + id)
+	}
+	kr := &keyring{primaryId: id, keys: map[string][]byte{id: primary}}
+
+	olds := kc.Old
+	if s := os.Getenv(envOldKeys); len(s) > 0 {
+		olds = append(olds, strings.Split(s, ",")...)
+	}
+	for _, old := range olds {
+		i := strings.Index(old, ":")
+		if i <= 0 || i > 255 {
+			return nil, errors.New("旧密钥格式应为 id:key")
+		}
+		oldId := strings.TrimSpace(old[:i])
+		if _, ok := kr.keys[oldId]; ok {
+			return nil, errors.New("密钥ID重复: " + oldId)
+		}
+		k, err := parseKey(old[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("密钥 %s 不正确: %v", oldId, err)
+		}
+		kr.keys[oldId] = k
+	}
+	return kr, nil
+}
+
+// 重新读取配置文件中的密钥，校验通过后整体替换，失败时保留原密钥
+func reloadKeys() (*keyring, error) {
+	ringMu.Lock()
+	defer ringMu.Unlock()
+
+	c := config{}
+	if err := gcfg.ReadFileInto(&c, iniFile); err != nil {
+		return nil, err
+	}
+	kr, err := loadKeyring(c.Key)
+	if err != nil {
+		return nil, err
+	}
+	ring.Store(kr)
+	return kr, nil
+}
+
+// 解析密钥字符串，支持 base64: 与 hex: 前缀，否则按原始字节处理
+func parseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+
+	var k []byte
+	var err error
+	switch {
+	case strings.HasPrefix(s, "base64:"):
+		k, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(s, "base64:"))
+	case strings.HasPrefix(s, "hex:"):
+		k, err = hex.DecodeString(strings.TrimPrefix(s, "hex:"))
+	default:
+		k = []byte(s)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// AES 只支持 16、24、32 位密钥
+	switch len(k) {
+	case 16, 24, 32:
+		return k, nil
+	}
+	return nil, fmt.Errorf("密钥长度必须为16、24或32字节，当前为%d字节", len(k))
+}
+
+// 加载主密钥，优先级：环境变量 > 密钥文件 > 配置文件
+func loadKey(kc keyConfig) ([]byte, error) {
+	if s := os.Getenv(envKey); len(s) > 0 {
+		return parseKey(s)
+	}
+
+	file := os.Getenv(envKeyFile)
+	if len(file) == 0 {
+		file = kc.File
+	}
+	if len(file) > 0 {
+		b, err := ioutil.ReadFile(resolvePath(file))
+		if err != nil {
+			return nil, err
+		}
+		return parseKey(string(b))
+	}
+
+	if len(kc.Key) > 0 {
+		return parseKey(kc.Key)
+	}
+	return nil, errors.New("未配置密钥，请先运行 keygen 生成密钥")
+}
+
+// 加载 RSA 私钥，未配置时不启用 RSA
+func loadRsaKey() error {
+	file := os.Getenv(envRsaKeyFile)
+	if len(file) == 0 {
+		file = conf.Key.RsaFile
+	}
+	if len(file) == 0 {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(resolvePath(file))
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return errors.New("private key error")
+	}
+	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	privateKey = b
+	publicKey = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})
+	return nil
+}
+
+// 相对路径按可执行文件所在目录处理
+func resolvePath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	path, err := os.Executable()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(filepath.Dir(path), p)
+}
+
+// keygen 子命令：生成 AES 密钥，可选生成 RSA 私钥
+func keygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	size := fs.Int("size", 32, "AES 密钥长度：16、24 或 32")
+	out := fs.String("o", "", "AES 密钥写入文件，默认输出到终端")
+	rsaOut := fs.String("rsa", "", "RSA 私钥写入文件，不填则不生成")
+	force := fs.Bool("force", false, "覆盖已存在的密钥文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch *size {
+	case 16, 24, 32:
+	default:
+		return errors.New("密钥长度必须为16、24或32")
+	}
+
+	k := make([]byte, *size)
+	if _, err := crand.Read(k); err != nil {
+		return err
+	}
+	encoded := "base64:" + base64.StdEncoding.EncodeToString(k)
+
+	if len(*out) == 0 {
+		fmt.Println(encoded)
+	} else if err := writeKeyFile(*out, []byte(encoded+"\n"), *force); err != nil {
+		return err
+	}
+
+	if len(*rsaOut) > 0 {
+		priv, err := rsa.GenerateKey(crand.Reader, 2048)
+		if err != nil {
+			return err
+		}
+		b := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+		if err := writeKeyFile(*rsaOut, b, *force); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 写入密钥文件，文件已存在时除非指定 force 否则不覆盖，避免误替换线上密钥
+func writeKeyFile(name string, data []byte, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(name, flags, 0600)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s 已存在，确认要替换时加 -force", name)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
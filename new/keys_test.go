@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteKeyFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "slider.key")
+
+	mustNil(t, writeKeyFile(name, []byte("first"), false))
+	if err := writeKeyFile(name, []byte("second"), false); err == nil {
+		t.Fatal("existing key file was overwritten without -force")
+	}
+	b, err := ioutil.ReadFile(name)
+	mustNil(t, err)
+	if string(b) != "first" {
+		t.Fatalf("key file = %q, want %q", b, "first")
+	}
+
+	mustNil(t, writeKeyFile(name, []byte("second"), true))
+	b, err = ioutil.ReadFile(name)
+	mustNil(t, err)
+	if string(b) != "second" {
+		t.Fatalf("key file = %q, want %q", b, "second")
+	}
+}
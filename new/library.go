@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 背景图库默认值
+const (
+	defaultLibraryDir      = "img"
+	defaultLibraryInterval = 30
+)
+
+// 背景图库为空
+var errEmptyLibrary = errors.New("没有可用的背景图")
+
+// 背景图库：启动时建立索引，定时轮询目录变化
+// 新增或修改的文件解码校验通过后才会加入，整份列表原子替换
+type imageLibrary struct {
+	dir   string
+	files atomic.Value // []string
+}
+
+// 全局背景图库
+var library *imageLibrary
+
+// 创建背景图库并建立索引
+func newImageLibrary(dir string) (l *imageLibrary, err error) {
+	l = &imageLibrary{dir: dir}
+	l.files.Store([]string(nil))
+	_, err = l.Refresh()
+	return
+}
+
+// 当前可用的背景图
+func (l *imageLibrary) Files() []string {
+	return l.files.Load().([]string)
+}
+
+// 随机选取一张背景图
+func (l *imageLibrary) Pick() (file string, err error) {
+	files := l.Files()
+	if len(files) == 0 {
+		err = errEmptyLibrary
+		return
+	}
+	file = files[rand.Intn(len(files))]
+	return
+}
+
+// 重新扫描目录，列表有变化时替换，返回是否有变化
+// 目录读取失败时保留原列表
+func (l *imageLibrary) Refresh() (changed bool, err error) {
+	files, err := readAllImageFiles(l.dir)
+	if err != nil {
+		return
+	}
+
+	old := l.Files()
+	if equalStrings(old, files) {
+		return
+	}
+	l.files.Store(files)
+	forgetImageFiles(l.dir, files)
+
+	logger.WithFields(logrus.Fields{"dir": l.dir, "old": len(old), "new": len(files)}).Info("背景图库已更新")
+	if len(files) == 0 {
+		logger.WithField("dir", l.dir).Warn("背景图库为空")
+	}
+	return true, nil
+}
+
+// 按 interval 秒轮询目录
+func (l *imageLibrary) Watch(interval int) {
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := l.Refresh(); err != nil {
+			logger.WithError(err).WithField("dir", l.dir).Error("背景图库刷新失败")
+		}
+	}
+}
+
+// 已校验过的背景图，文件未变化时不再重复解码
+var (
+	checkedMu  sync.Mutex
+	checkedImg = make(map[string]checkedFile)
+)
+
+// 背景图校验结果
+type checkedFile struct {
+	modTime time.Time
+	size    int64
+	ok      bool
+}
+
+// 支持的背景图格式
+var imageExtReg = regexp.MustCompile(`(?i).\.(png|jpe?g|gif|webp)$`)
+
+// 读取目录中的背景图，无法解码的文件记录日志后跳过
+func readAllImageFiles(path string) (fileName []string, err error) {
+
+	var files []os.FileInfo
+	// 读取文件
+	files, err = ioutil.ReadDir(path)
+	if err != nil {
+		err = errors.New("读取文件失败: " + path)
+		return
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		} else {
+			if imageExtReg.MatchString(file.Name()) && checkImageFile(path+"/"+file.Name(), file) {
+				fileName = append(fileName, path+"/"+file.Name())
+			}
+		}
+	}
+	return
+}
+
+// 校验背景图能否解码，结果按文件修改时间和大小缓存
+func checkImageFile(name string, info os.FileInfo) bool {
+	checkedMu.Lock()
+	defer checkedMu.Unlock()
+
+	if c, ok := checkedImg[name]; ok && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c.ok
+	}
+
+	_, err := getImg(name)
+	if err != nil {
+		logger.WithError(err).WithField("image", name).Warn("背景图无法解码，已跳过")
+	}
+	checkedImg[name] = checkedFile{modTime: info.ModTime(), size: info.Size(), ok: err == nil}
+	return err == nil
+}
+
+// 清理目录 dir 中已删除文件的校验记录
+func forgetImageFiles(dir string, files []string) {
+	keep := make(map[string]bool, len(files))
+	for _, f := range files {
+		keep[f] = true
+	}
+
+	checkedMu.Lock()
+	defer checkedMu.Unlock()
+	for name, c := range checkedImg {
+		if filepath.Dir(name) != filepath.Clean(dir) || keep[name] {
+			continue
+		}
+		// 无法解码但仍在目录中的文件保留记录，避免重复解码
+		if !c.ok {
+			if _, err := os.Stat(name); err == nil {
+				continue
+			}
+		}
+		delete(checkedImg, name)
+	}
+}
+
+// 比较两个字符串列表
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
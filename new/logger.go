@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"example.com/m/middlewares"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 全局日志，默认输出 JSON，读取配置后按 [Log] 调整
+var logger = newLogger()
+
+// 创建 JSON 日志
+func newLogger() *logrus.Logger {
+	l := logrus.New()
+	l.SetFormatter(&logrus.JSONFormatter{TimestampFormat: "2006-01-02T15:04:05.000Z07:00"})
+	return l
+}
+
+// 按配置设置日志级别与格式，第三方库通过标准库 log 输出的内容也写入该日志
+func initLogger() error {
+	if len(conf.Log.Level) > 0 {
+		level, err := logrus.ParseLevel(conf.Log.Level)
+		if err != nil {
+			return err
+		}
+		logger.SetLevel(level)
+	}
+
+	switch strings.ToLower(conf.Log.Format) {
+	case "", "json":
+	case "text":
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	default:
+		return errors.New("不支持的日志格式: " + conf.Log.Format)
+	}
+
+	log.SetFlags(0)
+	log.SetOutput(logger.WriterLevel(logrus.InfoLevel))
+
+	// gin 直接写 DefaultWriter 的内容同样写入该日志
+	gin.DefaultWriter = logger.WriterLevel(logrus.DebugLevel)
+	gin.DefaultErrorWriter = logger.WriterLevel(logrus.ErrorLevel)
+	return nil
+}
+
+// 带请求ID的日志
+func reqLog(c *gin.Context) *logrus.Entry {
+	return logger.WithField("request_id", c.GetString(middlewares.RequestIDKey))
+}
+
+// 把验证码ID、站点与类型写入 gin.Context，访问日志中记录
+func setLogSlider(c *gin.Context, slider SliderInfo) {
+	c.Set(middlewares.ChallengeIDKey, slider.Id)
+	c.Set(middlewares.SiteKey, slider.site().key)
+	c.Set(middlewares.TypeKey, slider.challengeType())
+}
+
+// 带请求ID、验证码ID、站点与类型的日志，不能记录答案
+func sliderLog(c *gin.Context, slider SliderInfo) *logrus.Entry {
+	return reqLog(c).WithFields(logrus.Fields{
+		"challenge_id": slider.Id,
+		"site":         slider.site().key,
+		"type":         slider.challengeType(),
+	})
+}
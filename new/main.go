@@ -1,35 +1,128 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
-	"image/color"
-	"image/draw"
-	"image/png"
-	"io/ioutil"
-	"log"
+	_ "image/gif"
+	_ "image/jpeg"
 	"math/rand"
 	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"time"
 
 	"example.com/m/middlewares"
-	"github.com/disintegration/imaging"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	_ "golang.org/x/image/webp"
 	"gopkg.in/gcfg.v1"
 )
 
 type config struct {
 	Section struct {
-		Port string
+		Port           string
+		Tolerance      int
+		Timeout        int      // 验证码有效期（秒）
+		LegacyCoord    bool     // 是否在 getCode 中返回明文坐标（已废弃）
+		Shape          string   // 滑块形状：jigsaw、square、circle
+		Decoys         int      // 背景图中干扰缺口数量，0~3
+		AngleTolerance int      // 旋转验证允许的角度误差（度）
+		Secret         string   // 业务后台调用 siteverify 的密钥
+		PassTimeout    int      // 验证通过凭证有效期（秒）
+		CbcGrace       int      // 升级后仍接受旧版 CBC 签名的时长（秒），0 为不接受
+		CbcSince       string   // 升级时间，RFC3339 格式，兼容期从该时间开始计算
+		Origin         []string // 默认站点允许跨域访问的来源，为空时不限制
+	}
+	Server struct {
+		ReadTimeout     int      // 读取请求超时（秒）
+		WriteTimeout    int      // 写入响应超时（秒）
+		IdleTimeout     int      // 空闲连接超时（秒）
+		ShutdownTimeout int      // 关闭时等待处理中请求的最长时间（秒）
+		DrainDelay      int      // 关闭前标记未就绪后等待负载均衡摘除的时间（秒）
+		TrustedProxy    []string // 可信代理的 IP 或 CIDR，只有来自可信代理的请求才读取 X-Forwarded-For
+	}
+	Store struct {
+		Type        string // 验证码状态存储：memory 或 redis
+		MaxAttempts int    // 每个验证码最多验证次数
+	}
+	RateLimit struct {
+		Enable       bool    // 是否开启限流
+		Store        string  // 限流存储：memory 或 redis，默认与 [Store] 一致
+		GetCodeRate  float64 // getCode 每秒补充的令牌数
+		GetCodeBurst int     // getCode 令牌桶容量
+		ImageRate    float64 // slider、sliderBac 每秒补充的令牌数
+		ImageBurst   int     // slider、sliderBac 令牌桶容量
+		VerifyRate   float64 // verify 每秒补充的令牌数
+		VerifyBurst  int     // verify 令牌桶容量
+	}
+	Track struct {
+		Require       bool    // 是否必须提交拖动轨迹
+		RiskThreshold float64 // 风险分值达到该值判定为机器
+		MinDuration   int     // 最短拖动时长（毫秒）
+		MaxDuration   int     // 最长拖动时长（毫秒）
+	}
+	Click struct {
+		Font        string // 字体文件，需包含候选字符的字形
+		Chars       string // 候选字符
+		Count       int    // 需要依次点击的字数
+		Distractors int    // 干扰字数
+	}
+	Text struct {
+		Font       []string // 字体文件，可配置多个，随机使用
+		Chars      string   // 候选字符
+		MinLength  int      // 最少字符数
+		MaxLength  int      // 最多字符数
+		NoiseLines int      // 干扰线条数
+	}
+	Library struct {
+		Dir       string // 背景图目录，相对路径基于程序所在目录
+		Interval  int    // 轮询目录变化的间隔（秒），负数关闭
+		MaxWidth  int    // 上传的背景图裁剪为 2:1 后缩小到的最大宽度
+		MaxUpload int    // 上传文件大小上限（MB）
+	}
+	Cache struct {
+		MaxMemory int // 背景图缓存内存上限（MB）
+	}
+	Output struct {
+		BacFormat   string // 背景图输出格式：jpeg、png、webp
+		PieceFormat string // 滑块图输出格式，含透明像素时不支持透明的格式会改用 png
+		Quality     int    // jpeg 压缩质量 1~100
+	}
+	Site   map[string]*siteConfig // 多站点配置，[Site "站点标识"]
+	Render renderConfig
+	Key    keyConfig
+	Admin  struct {
+		Token string // 管理接口访问令牌，为空时关闭管理接口
+	}
+	Log struct {
+		Level  string // 日志级别：debug、info、warn、error
+		Format string // 日志格式：json 或 text
+	}
+	Metrics struct {
+		Enable bool   // 是否开启 /metrics 监控接口
+		Token  string // 监控接口访问令牌，为空时不鉴权
+	}
+	Redis struct {
+		Addr     string
+		Password string
+		DB       int
 	}
 }
 
+// 密钥配置
+type keyConfig struct {
+	Id      string   // 主密钥ID，写入签名中
+	Key     string   // AES 密钥，支持 base64:/hex: 前缀
+	File    string   // AES 密钥文件
+	Old     []string // 仅用于解密的旧密钥，格式 id:key
+	RsaFile string   // RSA 私钥文件
+}
+
 // 自定义返回
 type JsonRes struct {
 	Status    int         `json:"status"`
@@ -40,54 +133,314 @@ type JsonRes struct {
 
 var listenAddr string
 
+// 系统配置
+var conf config
+
+// 配置文件路径
+var iniFile string
+
 // 图片信息
 type SliderInfo struct {
-	BacW    int    `json:"BacW"`
-	BacH    int    `json:"BacH"`
-	SliderW int    `json:"SliderW"`
-	SliderH int    `json:"SliderH"`
-	Dx      int    `json:"Dx"`
-	Dy      int    `json:"Dy"`
-	Src     string `json:"Src"`
-	Time    int64  `json:"Time"`
+	BacW    int     `json:"BacW"`
+	BacH    int     `json:"BacH"`
+	SliderW int     `json:"SliderW"`
+	SliderH int     `json:"SliderH"`
+	Dx      int     `json:"Dx"`
+	Dy      int     `json:"Dy"`
+	Src     string  `json:"Src"`
+	Time    int64   `json:"Time"`
+	Id      string  `json:"Id"`               // 验证码ID，用于防重放
+	Strip   bool    `json:"Strip,omitempty"`  // 滑块以整列图片返回，纵向位置由图片决定
+	Shape   string  `json:"Shape,omitempty"`  // 滑块形状
+	Seed    int64   `json:"Seed,omitempty"`   // 形状随机种子
+	Decoys  []Decoy `json:"Decoys,omitempty"` // 干扰缺口
+	Type    string  `json:"Type,omitempty"`   // 验证码类型
+	Angle   int     `json:"Angle,omitempty"`  // 旋转验证的旋转角度
+	Glyphs  []Glyph `json:"Glyphs,omitempty"` // 点选验证的字，前 Targets 个按顺序点击
+	Targets int     `json:"Targets,omitempty"`
+	Answer  string  `json:"Answer,omitempty"` // 字符验证的答案
+	Site    string  `json:"Site,omitempty"`   // 站点标识，默认站点为空
 }
 
 // 全局变量
 const (
-	bacH = 200                // 图片高度
-	bacW = 400                // 图片宽度
-	key  = "ABCDEFGHIJKLMNO1" // 16位
+	bacH = 200 // 图片高度
+	bacW = 400 // 图片宽度
+
+	minBacW = 100 // 前端可请求的最小背景图宽度，再小滑块与点选字放不下
+	maxBacW = 800 // 前端可请求的最大背景图宽度，防止超大图片耗尽内存与CPU
+
+	defaultTolerance = 5     // 默认允许误差（像素）
+	defaultTimeout   = 120   // 默认有效期（秒）
+	defaultAttempts  = 3     // 默认最多验证次数
+	defaultPassTime  = 300   // 默认验证通过凭证有效期（秒）
+	defaultRisk      = 0.7   // 默认风险阈值
+	defaultMinDrag   = 200   // 默认最短拖动时长（毫秒）
+	defaultMaxDrag   = 20000 // 默认最长拖动时长（毫秒）
+	defaultClicks    = 3     // 默认点选字数
+	defaultDistract  = 2     // 默认点选干扰字数
+	defaultTextMin   = 4     // 默认最少字符数
+	defaultTextMax   = 6     // 默认最多字符数
+	defaultNoise     = 4     // 默认干扰线条数
+)
+
+// 返回状态码
+const (
+	statusFail    = 0 // 失败
+	statusSuccess = 1 // 成功
+	statusExpired = 2 // 验证码已过期
+	statusUsed    = 3 // 验证码已使用或验证次数用尽
 )
 
+// 验证码已过期
+var errExpired = errors.New("验证码已过期")
+
 func main() {
 
+	// 生成密钥
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		if err := keygen(os.Args[2:]); err != nil {
+			fmt.Println("密钥生成失败:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	path, err := os.Executable()
 	if err != nil {
-		fmt.Println("路径获取不正确", err)
+		logger.WithError(err).Error("路径获取不正确")
 	}
 	dir := filepath.Dir(path)
 
 	// 获取配置文件
-	config := config{}
 	inifile := dir + "/conf/system.ini"
-	err = gcfg.ReadFileInto(&config, inifile)
+	iniFile = inifile
+	conf.Render = defaultRenderConfig()
+	err = gcfg.ReadFileInto(&conf, inifile)
+	if err != nil {
+		logger.WithError(err).WithField("file", inifile).Error("没有找到配置文件")
+		return
+	}
+	if err = initLogger(); err != nil {
+		logger.WithError(err).Error("日志配置（log）不正确")
+		return
+	}
+	if len(conf.Section.Port) == 0 {
+		logger.WithField("file", inifile).Error("端口号（port）不存在，或者不正确")
+		return
+	}
+	if conf.Section.Tolerance <= 0 {
+		conf.Section.Tolerance = defaultTolerance
+	}
+	if conf.Server.ReadTimeout <= 0 {
+		conf.Server.ReadTimeout = defaultReadTimeout
+	}
+	if conf.Server.WriteTimeout <= 0 {
+		conf.Server.WriteTimeout = defaultWriteTimeout
+	}
+	if conf.Server.IdleTimeout <= 0 {
+		conf.Server.IdleTimeout = defaultIdleTimeout
+	}
+	if conf.Server.ShutdownTimeout <= 0 {
+		conf.Server.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if conf.Server.DrainDelay < 0 {
+		conf.Server.DrainDelay = 0
+	}
+	if conf.Section.Timeout <= 0 {
+		conf.Section.Timeout = defaultTimeout
+	}
+	if conf.Section.PassTimeout <= 0 {
+		conf.Section.PassTimeout = defaultPassTime
+	}
+	if len(conf.Section.Secret) == 0 {
+		logger.WithField("file", inifile).Warn("密钥（secret）未配置，默认站点 siteverify 将不可用")
+	}
+	if err = loadCbcDeadline(); err != nil {
+		logger.WithError(err).Error("旧版签名兼容期（cbcGrace）配置不正确")
+		return
+	}
+	if conf.Track.RiskThreshold > 1 {
+		logger.WithField("riskThreshold", conf.Track.RiskThreshold).Warn("风险阈值（riskThreshold）超过1时轨迹检测不生效，已改用默认值")
+	}
+	if conf.Track.RiskThreshold <= 0 || conf.Track.RiskThreshold > 1 {
+		conf.Track.RiskThreshold = defaultRisk
+	}
+	if conf.Track.MinDuration <= 0 {
+		conf.Track.MinDuration = defaultMinDrag
+	}
+	if conf.Track.MaxDuration <= 0 {
+		conf.Track.MaxDuration = defaultMaxDrag
+	}
+	if conf.Section.AngleTolerance <= 0 {
+		conf.Section.AngleTolerance = defaultAngleTolerance
+	}
+	if len(conf.Section.Shape) == 0 {
+		conf.Section.Shape = defaultShape
+	}
+	if _, ok := shapes[conf.Section.Shape]; !ok {
+		logger.WithField("shape", conf.Section.Shape).Error("不支持的滑块形状（shape）")
+		return
+	}
+	if conf.Click.Count <= 0 {
+		conf.Click.Count = defaultClicks
+	}
+	if conf.Click.Distractors <= 0 {
+		conf.Click.Distractors = defaultDistract
+	}
+	if err = loadClickFont(); err != nil {
+		logger.WithError(err).Error("点选字体（click）加载失败")
+		return
+	}
+	if conf.Text.MinLength <= 0 {
+		conf.Text.MinLength = defaultTextMin
+	}
+	if conf.Text.MaxLength <= 0 {
+		conf.Text.MaxLength = defaultTextMax
+	}
+	if conf.Text.NoiseLines <= 0 {
+		conf.Text.NoiseLines = defaultNoise
+	}
+	if err = loadTextFonts(); err != nil {
+		logger.WithError(err).Error("字符验证字体（text）加载失败")
+		return
+	}
+	conf.Output.BacFormat = normalizeFormat(conf.Output.BacFormat)
+	if len(conf.Output.BacFormat) == 0 {
+		conf.Output.BacFormat = defaultBacFormat
+	}
+	conf.Output.PieceFormat = normalizeFormat(conf.Output.PieceFormat)
+	if len(conf.Output.PieceFormat) == 0 {
+		conf.Output.PieceFormat = defaultPieceFormat
+	}
+	for _, f := range []string{conf.Output.BacFormat, conf.Output.PieceFormat} {
+		if _, ok := encoders[f]; !ok {
+			logger.WithField("format", f).Error("不支持的图片输出格式（output）")
+			return
+		}
+	}
+	if conf.Output.Quality <= 0 || conf.Output.Quality > 100 {
+		conf.Output.Quality = defaultQuality
+	}
+	if conf.Cache.MaxMemory <= 0 {
+		conf.Cache.MaxMemory = defaultCacheMemory
+	}
+	imgCache = newBacCache(int64(conf.Cache.MaxMemory) << 20)
+	style, err = loadRenderStyle(conf.Render)
+	if err != nil {
+		logger.WithError(err).Error("绘制参数（render）不正确")
+		return
+	}
+	if conf.Store.MaxAttempts <= 0 {
+		conf.Store.MaxAttempts = defaultAttempts
+	}
+
+	// 加载密钥
+	kr, err := loadKeyring(conf.Key)
 	if err != nil {
-		fmt.Println("没有找到配置文件:", inifile)
+		logger.WithError(err).Error("密钥加载失败")
 		return
 	}
-	if len(config.Section.Port) == 0 {
-		fmt.Println("端口号（port）不存在，或者不正确:", inifile)
+	ring.Store(kr)
+	go watchKeyReload()
+	if err = loadRsaKey(); err != nil {
+		logger.WithError(err).Error("RSA 私钥加载失败")
 		return
 	}
 
-	r := gin.Default()
-	r.Use(middlewares.Cors())
+	// 验证码状态存储
+	store, err = newChallengeStore()
+	if err != nil {
+		logger.WithError(err).Error("验证码存储初始化失败")
+		return
+	}
+
+	// 限流
+	limiter, err = newRateLimiter()
+	if err != nil {
+		logger.WithError(err).Error("限流初始化失败")
+		return
+	}
+
+	// 背景图库，无法解码的文件记录日志后跳过
+	if len(conf.Library.Dir) == 0 {
+		conf.Library.Dir = defaultLibraryDir
+	}
+	if conf.Library.Interval == 0 {
+		conf.Library.Interval = defaultLibraryInterval
+	}
+	if conf.Library.MaxWidth < bacW {
+		conf.Library.MaxWidth = defaultMaxWidth
+	}
+	if conf.Library.MaxUpload <= 0 {
+		conf.Library.MaxUpload = defaultMaxUpload
+	}
+	library, err = newImageLibrary(resolvePath(conf.Library.Dir))
+	if err != nil {
+		logger.WithError(err).Error("背景图库加载失败")
+		return
+	}
+	if len(library.Files()) == 0 {
+		logger.WithField("dir", library.dir).Warn("没有可用的背景图")
+	}
+	if conf.Library.Interval > 0 {
+		go library.Watch(conf.Library.Interval)
+	}
+
+	// 多站点
+	if err = loadSites(); err != nil {
+		logger.WithError(err).Error("站点配置不正确")
+		return
+	}
+
+	proxies, err := middlewares.ParseProxies(conf.Server.TrustedProxy)
+	if err != nil {
+		logger.WithError(err).Error("可信代理（trustedProxy）配置不正确")
+		return
+	}
+
+	// gin 的调试输出是纯文本，会混入 JSON 日志，路由在注册完成后按 debug 级别记录
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	// 客户端 IP 只信任直连地址与可信代理转发的请求头，防止伪造 X-Forwarded-For 绕过限流
+	r.ForwardedByClientIP = false
+	r.Use(middlewares.RealIP(proxies), middlewares.RequestID(), middlewares.Logger(logger), middlewares.Recovery(logger))
+	r.Use(middlewares.Cors(anySiteAllowOrigin, "/admin", "/metrics"))
+
+	r.POST("/getCode", rateLimit(limitGetCode), getCode)
+	r.GET("/slider", rateLimit(limitImage), responseSlider)
+	r.GET("/sliderBac", rateLimit(limitImage), responseSliderBac)
+	r.POST("/verify", rateLimit(limitVerify), verify)
+	r.POST("/siteverify", siteVerify)
+	r.GET("/healthz", healthzHandler)
+	r.GET("/readyz", readyzHandler)
+
+	// 管理接口
+	// 监控指标
+	if conf.Metrics.Enable {
+		registerMetrics()
+		if len(conf.Metrics.Token) > 0 {
+			r.GET("/metrics", middlewares.AdminAuth(conf.Metrics.Token), metricsHandler())
+		} else {
+			r.GET("/metrics", metricsHandler())
+		}
+	}
 
-	r.POST("/getCode", getCode)
-	r.GET("/slider", responseSlider)
-	r.GET("/sliderBac", responseSliderBac)
+	admin := r.Group("/admin", middlewares.AdminAuth(conf.Admin.Token))
+	admin.POST("/keys/reload", reloadKeysHandler)
+	admin.GET("/cache", cacheStatsHandler)
+	admin.GET("/images", listImagesHandler)
+	admin.POST("/images", uploadImageHandler)
+	admin.GET("/images/:name", previewImageHandler)
+	admin.POST("/images/:name/disable", disableImageHandler)
+	admin.POST("/images/:name/enable", enableImageHandler)
+	admin.DELETE("/images/:name", deleteImageHandler)
+
+	for _, rt := range r.Routes() {
+		logger.WithFields(logrus.Fields{"method": rt.Method, "path": rt.Path, "handler": rt.Handler}).Debug("路由")
+	}
 
-	r.Run(config.Section.Port)
+	serve(newWebserver(r))
 }
 
 // 获取访问s值以及宽高
@@ -95,47 +448,91 @@ func getCode(c *gin.Context) {
 
 	// 获取请求参数
 	rbacW := c.PostForm("width")
+	// 站点标识，不传为默认站点
+	st, ok := getSite(c.PostForm("site"))
+	if !ok {
+		responseJson(c, statusFail, nil, "站点不存在")
+		return
+	}
+	if !checkOrigin(c, st) {
+		responseJson(c, statusFail, nil, "该来源不允许访问")
+		return
+	}
+	// 验证码类型，默认滑动拼图
+	ctype := c.DefaultPostForm("type", st.defaultType())
+	ct, ok := challengeTypes[ctype]
+	if !ok || !st.allowType(ctype) {
+		responseJson(c, statusFail, nil, "不支持的验证码类型")
+		return
+	}
+	// 旧前端依赖明文坐标，新前端传 mode=token 只拿签名
+	legacy := ctype == typeSlider && conf.Section.LegacyCoord && c.PostForm("mode") != "token"
 	// 转化成int型
 	width, err := strconv.Atoi(rbacW)
 	if err != nil {
 		width = 400
 	}
-
-	// 获取滑块尺寸
-	sliderInt := getSliderSize(width)
+	if width < minBacW || width > maxBacW {
+		responseJson(c, statusFail, nil, fmt.Sprintf("背景图宽度（width）需在 %d~%d 之间", minBacW, maxBacW))
+		return
+	}
 
 	// 获取背景高
 	hight := bacH * width / bacW
 
-	// 获取滑块位置
 	rand.Seed(time.Now().UnixNano())
-	dx := rand.Intn(width-2*sliderInt) + sliderInt
-	dy := rand.Intn(hight - sliderInt)
 
 	// 动态加载图片
-	src, err := getPic()
+	var src string
+	if !ct.noImage {
+		src, err = getPic(st)
+		if err != nil {
+			responseJson(c, statusFail, nil, "服务器图片无法加载，请及时联系管理人员")
+			return
+		}
+	}
+
+	// 登记验证码，用于防重放
+	id, err := newChallengeID()
+	if err != nil {
+		responseJson(c, statusFail, nil, "数据错误")
+		return
+	}
+	err = store.Add(id, st.ttl())
 	if err != nil {
-		responseJson(c, '0', nil, "服务器图片无法加载，请及时联系管理人员")
+		reqLog(c).WithError(err).WithFields(logrus.Fields{"challenge_id": id, "site": st.key}).Error("验证码登记失败")
+		responseJson(c, statusFail, nil, "数据错误")
 		return
 	}
 
 	slider := SliderInfo{
-		BacW:    width,             // 背景图宽度
-		BacH:    hight,             // 背景图高度
-		SliderW: sliderInt,         // 滑块宽度
-		SliderH: sliderInt,         // 滑块高度
-		Dx:      dx,                // 滑块位置x坐标
-		Dy:      dy,                // 滑块位置y坐标
-		Src:     src,               // 图片地址
-		Time:    time.Now().Unix(), // 时间戳
+		BacW: width,             // 背景图宽度
+		BacH: hight,             // 背景图高度
+		Src:  src,               // 图片地址
+		Time: time.Now().Unix(), // 时间戳
+		Id:   id,                // 验证码ID
+		Type: ctype,             // 验证码类型
+	}
+	if st.key != defaultSiteKey {
+		slider.Site = st.key
+	}
+	if ctype == typeSlider {
+		// 不返回坐标时滑块图带上纵向位置
+		slider.Strip = !legacy
 	}
+	ct.setup(&slider)
+	setLogSlider(c, slider)
 
 	source, err := json.Marshal(slider)
 	if err != nil {
-		responseJson(c, '0', nil, "数据错误")
+		responseJson(c, statusFail, nil, "数据错误")
+		return
+	}
+	sign, err := sealToken(source)
+	if err != nil {
+		responseJson(c, statusFail, nil, "数据错误")
 		return
 	}
-	sign := AesEncryptCBC(string(source), key)
 
 	// 处理为urlget请求
 	s := url.QueryEscape(sign)
@@ -143,10 +540,16 @@ func getCode(c *gin.Context) {
 	// 封装返回
 	var res map[string]string
 	res = make(map[string]string)
-	res["x"] = strconv.Itoa(dx)
-	res["y"] = strconv.Itoa(dy)
 	res["sign"] = s
+	if legacy {
+		// 明文坐标即答案，仅为兼容保留，迁移完成后关闭 legacyCoord
+		res["x"] = strconv.Itoa(slider.Dx)
+		res["y"] = strconv.Itoa(slider.Dy)
+		c.Header("Deprecation", "true")
+		c.Header("Warning", `299 - "x/y are deprecated, send mode=token and read the piece position from /slider"`)
+	}
 
+	challengesIssued.WithLabelValues(ctype, st.key).Inc()
 	responseJson(c, 1, res, "调用成功")
 }
 
@@ -155,25 +558,28 @@ func responseSlider(c *gin.Context) {
 
 	slider, err := getSliderInfo(c)
 	if err != nil {
-		log.Println(err)
-		responseJson(c, 0, nil, "请求参数s签名不正确")
+		reqLog(c).WithError(err).Info("签名解析失败")
+		responseSignError(c, err, "请求参数s签名不正确")
 		return
 	}
 
-	// 获取文件
-	img, err := getImg(slider.Src)
+	ct := challengeTypes[slider.challengeType()]
+	if ct.piece == nil {
+		responseJson(c, statusFail, nil, "该验证码类型没有滑块图片")
+		return
+	}
+
+	// 获取缩放后的背景图
+	start := time.Now()
+	img, err := imgCache.Get(slider.Src, slider.BacW, slider.BacH)
 	if err != nil {
-		log.Println(err)
+		sliderLog(c, slider).WithError(err).Error("背景图加载失败")
 		responseJson(c, 0, nil, "文件查询不到")
 		return
 	}
 
-	img = imaging.Resize(img, slider.BacW, slider.BacH, imaging.Lanczos)
-
-	rgba := image.NewRGBA(image.Rect(0, 0, slider.SliderW, slider.SliderH))
-	draw.Draw(rgba, rgba.Bounds(), img, image.Pt(slider.Dx, slider.Dy), draw.Src)
-
-	png.Encode(c.Writer, rgba)
+	writeImage(c, ct.piece(img, slider), conf.Output.PieceFormat)
+	observeRender("slider", slider, start)
 }
 
 // 返回背景图片
@@ -182,37 +588,31 @@ func responseSliderBac(c *gin.Context) {
 	// 获取图片参数
 	slider, err := getSliderInfo(c)
 	if err != nil {
-		responseJson(c, 0, nil, "请求参数s签名不正确")
+		responseSignError(c, err, "请求参数s签名不正确")
 		return
 	}
 
-	// 获取文件
-	img, err := getImg(slider.Src)
-	if err != nil {
-		log.Println(err)
-		responseJson(c, 0, nil, "文件查询不到")
+	ct := challengeTypes[slider.challengeType()]
+	if ct.background == nil {
+		responseJson(c, statusFail, nil, "不支持的验证码类型")
 		return
 	}
 
-	// 压缩图片大小
-	img = imaging.Resize(img, slider.BacW, slider.BacH, imaging.Lanczos)
-
-	// // 设置滑块大小
-	alpha := image.NewAlpha(image.Rect(0, 0, slider.SliderW, slider.SliderH))
-	for x := 0; x < slider.SliderW; x++ {
-		for y := 0; y < slider.SliderH; y++ {
-			alpha.Set(x, y, color.Alpha{uint8(100 % 256)}) //设定alpha图片的透明度
+	// 获取文件
+	start := time.Now()
+	var img image.Image
+	if !ct.noImage {
+		// 获取缩放后的背景图
+		img, err = imgCache.Get(slider.Src, slider.BacW, slider.BacH)
+		if err != nil {
+			sliderLog(c, slider).WithError(err).Error("背景图加载失败")
+			responseJson(c, 0, nil, "文件查询不到")
+			return
 		}
 	}
 
-	// 绘图的背景图。
-	dist := image.NewRGBA(image.Rect(0, 0, slider.BacW, slider.BacH))
-	siiderRect := image.Rect(slider.Dx, slider.Dy, slider.Dx+slider.SliderW, slider.Dy+slider.SliderH)
-
-	draw.Draw(dist, dist.Bounds(), img, image.ZP, draw.Src)
-	draw.Draw(dist, siiderRect, alpha, image.ZP, draw.Over)
-
-	png.Encode(c.Writer, dist)
+	writeImage(c, ct.background(img, slider), conf.Output.BacFormat)
+	observeRender("sliderBac", slider, start)
 }
 
 // 获取文件并转码
@@ -225,7 +625,8 @@ func getImg(SrcStr string) (img image.Image, err error) {
 	}
 	defer fileObj.Close()
 
-	img, err = png.Decode(fileObj)
+	// 按文件内容识别格式，支持 png、jpeg、gif、webp
+	img, _, err = image.Decode(fileObj)
 	if err != nil {
 		return
 	}
@@ -237,17 +638,99 @@ func getSliderInfo(c *gin.Context) (slider SliderInfo, err error) {
 	// 获取请求参数
 	s := c.Query("s")
 
+	slider, err = decodeSliderInfo(s)
+	if len(slider.Id) > 0 {
+		setLogSlider(c, slider)
+	}
+	return
+}
+
+// 解密签名，获取滑动验证详情
+func decodeSliderInfo(s string) (slider SliderInfo, err error) {
+	if len(s) == 0 {
+		err = errors.New("签名为空")
+		return
+	}
+
 	// 解密转换
-	sign := AesDecryptCBC(s, key)
+	sign, err := openToken(s)
+	if err != nil {
+		return
+	}
 	slider = SliderInfo{}
 
-	err = myUnmarshal([]byte(sign), &slider)
+	err = myUnmarshal(sign, &slider)
 	if err != nil {
 		return
 	}
+	// 旧版签名的密钥是公开的，尺寸同样需要校验
+	if slider.BacW < minBacW || slider.BacW > maxBacW || slider.BacH != bacH*slider.BacW/bacW {
+		err = errors.New("签名内容不正确")
+		return
+	}
+
+	// 校验有效期
+	if time.Now().Unix()-slider.Time > int64(slider.site().timeout) {
+		err = errExpired
+		return
+	}
+
+	// 旧版签名没有验证码ID，按签名内容生成ID，首次使用时登记，同样只能验证通过一次
+	if len(slider.Id) == 0 {
+		if err = checkLegacySlider(slider); err != nil {
+			return
+		}
+		slider.Id = legacyChallengeID(s)
+		err = store.Check(slider.Id)
+		if err == errNotFound {
+			ttl := time.Until(time.Unix(slider.Time, 0).Add(slider.site().ttl()))
+			err = store.Add(slider.Id, ttl+time.Second)
+		}
+		return
+	}
+
+	// 已验证通过或次数用尽的验证码不再可用
+	err = store.Check(slider.Id)
 	return
 }
 
+// 旧版签名的密钥是公开的，只接受旧版 getCode 能生成的内容：
+// 升级前签发、只有滑动拼图字段、滑块尺寸与位置在旧版取值范围内、背景图在站点图库中
+func checkLegacySlider(slider SliderInfo) error {
+	invalid := errors.New("旧版签名内容不正确")
+
+	if slider.Time > cbcSince.Unix() {
+		return invalid
+	}
+	if slider.Strip || len(slider.Shape) > 0 || slider.Seed != 0 || len(slider.Decoys) > 0 ||
+		len(slider.Type) > 0 || slider.Angle != 0 || len(slider.Glyphs) > 0 || slider.Targets != 0 ||
+		len(slider.Answer) > 0 || len(slider.Site) > 0 {
+		return invalid
+	}
+
+	size := getSliderSize(slider.BacW)
+	if slider.SliderW != size || slider.SliderH != size {
+		return invalid
+	}
+	if slider.Dx < size || slider.Dx >= slider.BacW-size || slider.Dy < 0 || slider.Dy >= slider.BacH-size {
+		return invalid
+	}
+
+	src := filepath.Clean(slider.Src)
+	for _, f := range slider.site().library.Files() {
+		if filepath.Clean(f) == src {
+			return nil
+		}
+	}
+	return invalid
+}
+
+// 旧版签名的验证码ID
+func legacyChallengeID(sign string) string {
+	sum := sha256.Sum256([]byte(sign))
+	return "cbc-" + hex.EncodeToString(sum[:])
+}
+
 // json 解码
 func myUnmarshal(input []byte, target interface{}) error {
 	if len(input) == 0 {
@@ -268,6 +751,19 @@ func responseJson(c *gin.Context, status int, data interface{}, msg string) {
 	})
 }
 
+// 签名解析失败时返回，过期单独返回状态码
+func responseSignError(c *gin.Context, err error, msg string) {
+	if err == errExpired {
+		responseJson(c, statusExpired, nil, "验证码已过期，请重新获取")
+		return
+	}
+	if err == errConsumed || err == errNotFound {
+		responseJson(c, statusUsed, nil, "验证码已失效，请重新获取")
+		return
+	}
+	responseJson(c, statusFail, nil, msg)
+}
+
 // 根据背景图大小，获取滑块实际大小
 func getSliderSize(w int) int {
 
@@ -286,46 +782,7 @@ func getSliderSize(w int) int {
 	return sliderW
 }
 
-// 获取随机图片地址
-func getPic() (file string, err error) {
-
-	path, err := os.Executable()
-	if err != nil {
-		fmt.Println("路径获取不正确", err)
-	}
-	dir := filepath.Dir(path)
-	log.Println(dir)
-
-	files, err := readAllPngFiles(dir + "/img")
-	if err != nil {
-		return
-	}
-	randInt := rand.Intn(len(files) - 1)
-
-	file = files[randInt]
-	return
-}
-
-// 动态获取背景图
-func readAllPngFiles(path string) (fileName []string, err error) {
-
-	var files []os.FileInfo
-	// 读取文件
-	files, err = ioutil.ReadDir(path)
-	if err != nil {
-		err = errors.New("读取文件失败: " + path)
-		return
-	}
-
-	var reg = regexp.MustCompile(`.\.{1}png$`)
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		} else {
-			if reg.MatchString(file.Name()) {
-				fileName = append(fileName, path+"/"+file.Name())
-			}
-		}
-	}
-	return
+// 从站点背景图库中随机获取图片地址
+func getPic(st *site) (file string, err error) {
+	return st.library.Pick()
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 超出范围的宽度在登记验证码之前被拒绝
+func TestGetCodeRejectsWidthOutOfRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldStore, oldSites := store, sites
+	t.Cleanup(func() { store, sites = oldStore, oldSites })
+	ms := newMemoryStore(time.Minute)
+	store = ms
+	sites = map[string]*site{defaultSiteKey: {key: defaultSiteKey, timeout: 120}}
+
+	for _, width := range []string{"-1", "0", "59", "99", "801", "20000"} {
+		for _, ctype := range []string{typeSlider, typeText} {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			form := url.Values{"width": {width}, "type": {ctype}}
+			c.Request = httptest.NewRequest("POST", "/getCode", strings.NewReader(form.Encode()))
+			c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			getCode(c)
+
+			var res JsonRes
+			mustNil(t, json.Unmarshal(w.Body.Bytes(), &res))
+			if res.Status != statusFail {
+				t.Errorf("width %s type %s: status = %d, want %d", width, ctype, res.Status, statusFail)
+			}
+		}
+	}
+	if len(ms.items) != 0 {
+		t.Fatalf("%d challenges registered for rejected widths", len(ms.items))
+	}
+}
+
+// 旧前端使用的小尺寸仍然可用
+func TestGetCodeAcceptsSmallWidth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestKeyring(t)
+	oldStore, oldSites := store, sites
+	t.Cleanup(func() { store, sites = oldStore, oldSites })
+	store = newMemoryStore(time.Minute)
+	lib := &imageLibrary{dir: "img"}
+	lib.files.Store([]string{"img/1.png"})
+	sites = map[string]*site{defaultSiteKey: {key: defaultSiteKey, timeout: 120, library: lib}}
+
+	for _, width := range []string{"100", "150", "800"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		form := url.Values{"width": {width}, "mode": {"token"}}
+		c.Request = httptest.NewRequest("POST", "/getCode", strings.NewReader(form.Encode()))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		getCode(c)
+
+		var res JsonRes
+		mustNil(t, json.Unmarshal(w.Body.Bytes(), &res))
+		if res.Status != statusSuccess {
+			t.Errorf("width %s: status = %d, msg = %s", width, res.Status, res.Msg)
+		}
+	}
+}
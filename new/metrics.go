@@ -0,0 +1,83 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 校验结果
+const (
+	verifyPass     = "pass"     // 通过
+	verifyFail     = "fail"     // 答案错误或风险过高
+	verifyExpired  = "expired"  // 验证码已过期
+	verifyReplayed = "replayed" // 验证码已使用或不存在，可能是重放
+	verifyInvalid  = "invalid"  // 签名或参数不正确
+)
+
+// 监控指标
+var (
+	challengesIssued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slider_challenges_issued_total",
+		Help: "已签发的验证码数量",
+	}, []string{"type", "site"})
+
+	renderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "slider_render_duration_seconds",
+		Help:    "滑块图与背景图的绘制与编码耗时",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"handler", "type"})
+
+	verifyResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slider_verify_total",
+		Help: "校验次数，result 为 pass、fail、expired、replayed、invalid",
+	}, []string{"result", "site"})
+
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slider_ratelimit_rejections_total",
+		Help: "被限流拒绝的请求数",
+	}, []string{"endpoint"})
+)
+
+// 注册监控指标，缓存命中率由 hits/(hits+misses) 计算
+func registerMetrics() {
+	prometheus.MustRegister(challengesIssued, renderDuration, verifyResults, rateLimited)
+	prometheus.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "slider_image_cache_hits_total",
+			Help: "背景图缓存命中次数",
+		}, func() float64 { return float64(imgCache.Stats().Hits) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "slider_image_cache_misses_total",
+			Help: "背景图缓存未命中次数",
+		}, func() float64 { return float64(imgCache.Stats().Misses) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "slider_image_cache_bytes",
+			Help: "背景图缓存占用内存",
+		}, func() float64 { return float64(imgCache.Stats().Bytes) }),
+	)
+}
+
+// 监控指标接口
+func metricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// 记录一次图片绘制耗时
+func observeRender(handler string, slider SliderInfo, start time.Time) {
+	renderDuration.WithLabelValues(handler, slider.challengeType()).Observe(time.Since(start).Seconds())
+}
+
+// 签名解析错误对应的校验结果
+func verifyOutcome(err error) string {
+	switch err {
+	case errExpired:
+		return verifyExpired
+	case errConsumed, errNotFound:
+		return verifyReplayed
+	default:
+		return verifyInvalid
+	}
+}
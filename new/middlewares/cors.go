@@ -2,18 +2,39 @@ package middlewares
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Cors() gin.HandlerFunc {
+// allowOrigin 判断来源是否允许跨域访问，不允许的来源不返回 Access-Control-Allow-Origin
+// 只有来源被明确列出（不是 *）时 credentials 为 true，才返回 Access-Control-Allow-Credentials
+// exclude 中前缀开头的路径（如管理接口）不支持跨域访问
+func Cors(allowOrigin func(origin string) (allowed, credentials bool), exclude ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		for _, prefix := range exclude {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
 		method := c.Request.Method
-		c.Header("Access-Control-Allow-Origin", "*") // 可将将 * 替换为指定的域名
+		origin := c.GetHeader("Origin")
+		c.Header("Vary", "Origin")
+		credentials := false
+		if len(origin) == 0 {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else if allowed, explicit := allowOrigin(origin); allowed {
+			c.Header("Access-Control-Allow-Origin", origin)
+			credentials = explicit
+		}
 		c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
 		c.Header("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization")
 		c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Cache-Control, Content-Language, Content-Type")
-		c.Header("Access-Control-Allow-Credentials", "true")
+		if credentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
 		if method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
 		}
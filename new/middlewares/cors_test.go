@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	allow := func(origin string) (bool, bool) {
+		switch origin {
+		case "https://shop.example.com":
+			return true, true
+		case "https://any.example.com":
+			return true, false
+		}
+		return false, false
+	}
+
+	r := gin.New()
+	r.Use(Cors(allow, "/admin"))
+	r.GET("/getCode", func(c *gin.Context) {})
+	r.GET("/admin/images", func(c *gin.Context) {})
+
+	tests := []struct {
+		name        string
+		path        string
+		origin      string
+		allowOrigin string
+		credentials string
+	}{
+		{"explicit origin", "/getCode", "https://shop.example.com", "https://shop.example.com", "true"},
+		{"wildcard origin", "/getCode", "https://any.example.com", "https://any.example.com", ""},
+		{"denied origin", "/getCode", "https://evil.example.com", "", ""},
+		{"no origin", "/getCode", "", "*", ""},
+		{"admin", "/admin/images", "https://shop.example.com", "", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if len(tt.origin) > 0 {
+			req.Header.Set("Origin", tt.origin)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+			t.Errorf("%s: Allow-Origin = %q, want %q", tt.name, got, tt.allowOrigin)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+			t.Errorf("%s: Allow-Credentials = %q, want %q", tt.name, got, tt.credentials)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 接口写入的验证码ID、站点与类型出现在访问日志中
+func TestLoggerChallengeFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	buf := &bytes.Buffer{}
+	l := logrus.New()
+	l.SetOutput(buf)
+	l.SetFormatter(&logrus.JSONFormatter{})
+
+	r := gin.New()
+	r.Use(RequestID(), Logger(l))
+	r.POST("/getCode", func(c *gin.Context) {
+		c.Set(ChallengeIDKey, "abc")
+		c.Set(SiteKey, "shop")
+		c.Set(TypeKey, "slider")
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/getCode", nil))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log is not a single JSON line: %q", buf.String())
+	}
+	if entry["challenge_id"] != "abc" || entry["site"] != "shop" || entry["type"] != "slider" {
+		t.Fatalf("log entry = %v", entry)
+	}
+}
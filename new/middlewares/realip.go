@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 解析可信代理列表，支持单个 IP 与 CIDR
+func ParseProxies(proxies []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("可信代理地址不正确: %s", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("可信代理地址不正确: %s", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// 客户端真实 IP，需配合 engine.ForwardedByClientIP = false 使用
+// 只有直连的对端是可信代理时才读取 X-Forwarded-For 与 X-Real-Ip，
+// 从右往左取第一个不是可信代理的地址，并写回 RemoteAddr，之后 c.ClientIP() 返回该地址
+// 客户端直连时请求头可以任意伪造，一律忽略
+func RealIP(trusted []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ip := realIP(c, trusted); ip != nil {
+			c.Request.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+		}
+		c.Next()
+	}
+}
+
+// 从代理请求头中取客户端地址，不需要改写时返回 nil
+func realIP(c *gin.Context, trusted []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
+	if err != nil || !isTrusted(net.ParseIP(host), trusted) {
+		return nil
+	}
+
+	var client net.IP
+	if xff := c.GetHeader("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			client = ip
+			if !isTrusted(ip, trusted) {
+				break
+			}
+		}
+		return client
+	}
+	return net.ParseIP(strings.TrimSpace(c.GetHeader("X-Real-Ip")))
+}
+
+// 是否为可信代理
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRealIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	trusted, err := ParseProxies([]string{"10.0.0.0/8", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		realIP string
+		want   string
+	}{
+		{"direct client", "203.0.113.7:5000", "", "", "203.0.113.7"},
+		{"direct client spoofing xff", "203.0.113.7:5000", "1.2.3.4", "5.6.7.8", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:5000", "198.51.100.9", "", "198.51.100.9"},
+		{"spoofed hop before proxy", "10.0.0.2:5000", "1.2.3.4, 198.51.100.9", "", "198.51.100.9"},
+		{"chain of trusted proxies", "127.0.0.1:5000", "198.51.100.9, 10.1.1.1", "", "198.51.100.9"},
+		{"x-real-ip from trusted proxy", "10.0.0.2:5000", "", "198.51.100.9", "198.51.100.9"},
+		{"garbage from trusted proxy", "10.0.0.2:5000", "not-an-ip", "", "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		r.ForwardedByClientIP = false
+		var got string
+		r.Use(RealIP(trusted))
+		r.GET("/", func(c *gin.Context) { got = c.ClientIP() })
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		if len(tt.xff) > 0 {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if len(tt.realIP) > 0 {
+			req.Header.Set("X-Real-Ip", tt.realIP)
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("%s: ClientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	if _, err := ParseProxies([]string{"10.0.0.0/8", "::1", "192.168.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatal("invalid CIDR accepted")
+	}
+	if _, err := ParseProxies([]string{"proxy.local"}); err == nil {
+		t.Fatal("hostname accepted")
+	}
+}
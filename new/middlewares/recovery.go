@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 捕获 panic 并记录带请求ID的日志，替代 gin 默认的 Recovery
+// 客户端断开导致的写入失败不记录堆栈
+func Recovery(l *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			entry := l.WithFields(logrus.Fields{
+				"request_id": c.GetString(RequestIDKey),
+				"method":     c.Request.Method,
+				"path":       c.Request.URL.Path,
+				"panic":      err,
+			})
+			if brokenPipe(err) {
+				entry.Warn("客户端连接已断开")
+				c.Error(err.(error)) // nolint: errcheck
+				c.Abort()
+				return
+			}
+			entry.WithField("stack", string(debug.Stack())).Error("请求处理发生 panic")
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}()
+		c.Next()
+	}
+}
+
+// 是否为客户端断开连接导致的错误
+func brokenPipe(err interface{}) bool {
+	var ne *net.OpError
+	e, ok := err.(error)
+	if !ok || !errors.As(e, &ne) {
+		return false
+	}
+	var se *os.SyscallError
+	if !errors.As(ne.Err, &se) {
+		return false
+	}
+	msg := strings.ToLower(se.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
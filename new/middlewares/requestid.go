@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求ID在 gin.Context 中的键
+const RequestIDKey = "requestId"
+
+// 请求ID请求头与响应头
+const requestIDHeader = "X-Request-ID"
+
+// 上游传入的请求ID只接受常见字符，防止日志注入
+var requestIDReg = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// 请求ID，沿用上游传入的 X-Request-ID，没有时生成，并写入响应头
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !requestIDReg.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Set(RequestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
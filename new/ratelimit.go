@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 限流的接口分组
+const (
+	limitGetCode = "getCode" // 生成验证码
+	limitImage   = "image"   // 滑块图与背景图
+	limitVerify  = "verify"  // 校验答案
+)
+
+// 限流默认值：每秒补充的令牌数与桶容量
+const (
+	defaultGetCodeRate  = 1
+	defaultGetCodeBurst = 10
+	defaultImageRate    = 4
+	defaultImageBurst   = 20
+	defaultVerifyRate   = 2
+	defaultVerifyBurst  = 10
+)
+
+// 令牌桶参数
+type limitRule struct {
+	rate  float64 // 每秒补充的令牌数
+	burst int     // 桶容量，即允许的突发请求数
+}
+
+// 令牌桶限流，allowed 为 false 时 retryAfter 为下一个令牌可用的等待时间
+type RateLimiter interface {
+	Allow(key string, rule limitRule) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// 全局限流器，未开启限流时为 nil
+var limiter RateLimiter
+
+// 各接口分组的令牌桶参数
+var limitRules map[string]limitRule
+
+// 根据配置创建限流器，存储默认与验证码状态存储一致
+func newRateLimiter() (RateLimiter, error) {
+	if !conf.RateLimit.Enable {
+		return nil, nil
+	}
+
+	limitRules = map[string]limitRule{
+		limitGetCode: newLimitRule(conf.RateLimit.GetCodeRate, conf.RateLimit.GetCodeBurst, defaultGetCodeRate, defaultGetCodeBurst),
+		limitImage:   newLimitRule(conf.RateLimit.ImageRate, conf.RateLimit.ImageBurst, defaultImageRate, defaultImageBurst),
+		limitVerify:  newLimitRule(conf.RateLimit.VerifyRate, conf.RateLimit.VerifyBurst, defaultVerifyRate, defaultVerifyBurst),
+	}
+
+	storeType := conf.RateLimit.Store
+	if len(storeType) == 0 {
+		storeType = conf.Store.Type
+	}
+	switch storeType {
+	case "", "memory":
+		return newMemoryLimiter(time.Minute), nil
+	case "redis":
+		return newRedisLimiter(newRedisClient())
+	default:
+		return nil, errors.New("不支持的限流存储类型: " + storeType)
+	}
+}
+
+// 令牌桶参数，未配置时使用默认值
+func newLimitRule(rate float64, burst int, defRate float64, defBurst int) limitRule {
+	if rate <= 0 {
+		rate = defRate
+	}
+	if burst <= 0 {
+		burst = defBurst
+	}
+	return limitRule{rate: rate, burst: burst}
+}
+
+// 限流中间件，按客户端 IP、站点与接口分组分别计数
+// 超出限制返回 429 与 Retry-After，限流存储出错时放行
+func rateLimit(endpoint string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		key := strings.Join([]string{endpoint, requestSite(c), c.ClientIP()}, ":")
+		allowed, retryAfter, err := limiter.Allow(key, limitRules[endpoint])
+		if err != nil {
+			reqLog(c).WithError(err).Error("限流存储出错")
+			c.Next()
+			return
+		}
+		if !allowed {
+			rateLimited.WithLabelValues(endpoint).Inc()
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		c.Next()
+	}
+}
+
+// 请求所属站点：getCode 取参数 site，其余接口从签名中读取
+// 无法识别时按默认站点计数
+func requestSite(c *gin.Context) string {
+	if key := c.PostForm("site"); len(key) > 0 {
+		if st, ok := getSite(key); ok {
+			return st.key
+		}
+		return defaultSiteKey
+	}
+
+	s := c.Query("s")
+	if len(s) == 0 {
+		s = c.PostForm("sign")
+		if strings.Contains(s, "%") {
+			if u, err := url.QueryUnescape(s); err == nil {
+				s = u
+			}
+		}
+	}
+	if len(s) == 0 {
+		return defaultSiteKey
+	}
+	plain, err := openToken(s)
+	if err != nil {
+		return defaultSiteKey
+	}
+	var slider SliderInfo
+	if json.Unmarshal(plain, &slider) != nil {
+		return defaultSiteKey
+	}
+	return slider.site().key
+}
+
+// 单个令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // 桶补满的时间
+}
+
+// 内存限流，适用于单机部署
+type memoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// 创建内存限流器，并定期清理已补满的令牌桶
+func newMemoryLimiter(cleanup time.Duration) *memoryLimiter {
+	m := &memoryLimiter{buckets: make(map[string]*bucket)}
+	go func() {
+		for range time.Tick(cleanup) {
+			m.purge()
+		}
+	}()
+	return m
+}
+
+func (m *memoryLimiter) Allow(key string, rule limitRule) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.burst), last: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(rule.burst), b.tokens+now.Sub(b.last).Seconds()*rule.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		b.full = now.Add(time.Duration((float64(rule.burst) - b.tokens) / rule.rate * float64(time.Second)))
+		return true, 0, nil
+	}
+	wait := (1 - b.tokens) / rule.rate
+	return false, time.Duration(wait * float64(time.Second)), nil
+}
+
+// 清理已补满的令牌桶，删除后与新建等价
+func (m *memoryLimiter) purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for key, b := range m.buckets {
+		if now.After(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
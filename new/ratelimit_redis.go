@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 限流令牌桶 redis key 前缀
+const redisLimitPrefix = "slider:limit:"
+
+// 令牌桶：ARGV 为每秒补充令牌数、桶容量、当前毫秒时间戳
+// 返回 {是否放行, 需等待的毫秒数}
+var limitScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local b = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(b[1])
+local ts = tonumber(b[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) * 1000 / rate) + 1000)
+return {allowed, wait}
+`)
+
+// redis 限流，适用于多机部署，各节点时钟需要同步
+type redisLimiter struct {
+	client *redis.Client
+}
+
+// 创建 redis 限流器，并检查连接
+func newRedisLimiter(client *redis.Client) (*redisLimiter, error) {
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+	return &redisLimiter{client: client}, nil
+}
+
+func (r *redisLimiter) Allow(key string, rule limitRule) (bool, time.Duration, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	res, err := limitScript.Run(context.Background(), r.client, []string{redisLimitPrefix + key},
+		strconv.FormatFloat(rule.rate, 'f', -1, 64), rule.burst, now).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) != 2 {
+		return false, 0, errors.New("限流脚本返回值不正确")
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
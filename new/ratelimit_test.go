@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"example.com/m/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMemoryLimiterAllow(t *testing.T) {
+	m := newMemoryLimiter(time.Minute)
+	rule := limitRule{rate: 1, burst: 3}
+
+	for i := 0; i < rule.burst; i++ {
+		allowed, _, err := m.Allow("a", rule)
+		mustNil(t, err)
+		if !allowed {
+			t.Fatalf("request %d denied within burst", i+1)
+		}
+	}
+	allowed, retryAfter, err := m.Allow("a", rule)
+	mustNil(t, err)
+	if allowed {
+		t.Fatal("request allowed after burst")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Fatalf("retryAfter = %v, want (0, 1s]", retryAfter)
+	}
+
+	// 其他 key 单独计数
+	if allowed, _, _ := m.Allow("b", rule); !allowed {
+		t.Fatal("independent key denied")
+	}
+
+	// 经过一秒补充一个令牌
+	m.buckets["a"].last = m.buckets["a"].last.Add(-time.Second)
+	if allowed, _, _ := m.Allow("a", rule); !allowed {
+		t.Fatal("request denied after refill")
+	}
+	if allowed, _, _ := m.Allow("a", rule); allowed {
+		t.Fatal("refill added more than one token")
+	}
+}
+
+// 客户端每次换一个 X-Forwarded-For 也不能拿到新的令牌桶
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldLimiter, oldRules, oldSites := limiter, limitRules, sites
+	t.Cleanup(func() { limiter, limitRules, sites = oldLimiter, oldRules, oldSites })
+	limiter = newMemoryLimiter(time.Minute)
+	limitRules = map[string]limitRule{limitVerify: {rate: 0.001, burst: 2}}
+	sites = map[string]*site{defaultSiteKey: {key: defaultSiteKey}}
+
+	r := gin.New()
+	r.ForwardedByClientIP = false
+	r.Use(middlewares.RealIP(nil))
+	r.POST("/verify", rateLimit(limitVerify), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	codes := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "/verify", nil)
+		req.RemoteAddr = "203.0.113.7:5000"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	if codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("status codes = %v, want third request limited", codes)
+	}
+}
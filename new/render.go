@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 缺口与滑块的绘制参数，颜色为 #rrggbb，透明度为 0~255
+type renderConfig struct {
+	HoleColor   string // 缺口内填充色
+	HoleAlpha   int
+	ShadowColor string // 缺口左上方内阴影
+	ShadowAlpha int
+	ShadowWidth int
+	BevelColor  string // 缺口右下方高光
+	BevelAlpha  int
+	BevelWidth  int
+	BorderColor string // 滑块描边
+	BorderAlpha int
+	BorderWidth int
+}
+
+// 默认绘制参数，配置文件中未填写的项沿用默认值
+func defaultRenderConfig() renderConfig {
+	return renderConfig{
+		HoleColor:   "#000000",
+		HoleAlpha:   110,
+		ShadowColor: "#000000",
+		ShadowAlpha: 150,
+		ShadowWidth: 3,
+		BevelColor:  "#ffffff",
+		BevelAlpha:  130,
+		BevelWidth:  1,
+		BorderColor: "#ffffff",
+		BorderAlpha: 200,
+		BorderWidth: 1,
+	}
+}
+
+// 解析后的绘制参数
+type renderStyle struct {
+	hole, shadow, bevel, border color.NRGBA
+	shadowWidth                 int
+	bevelWidth                  int
+	borderWidth                 int
+}
+
+// 全局绘制参数
+var style renderStyle
+
+// 解析绘制参数
+func loadRenderStyle(rc renderConfig) (s renderStyle, err error) {
+	if s.hole, err = parseColor(rc.HoleColor, rc.HoleAlpha); err != nil {
+		return
+	}
+	if s.shadow, err = parseColor(rc.ShadowColor, rc.ShadowAlpha); err != nil {
+		return
+	}
+	if s.bevel, err = parseColor(rc.BevelColor, rc.BevelAlpha); err != nil {
+		return
+	}
+	if s.border, err = parseColor(rc.BorderColor, rc.BorderAlpha); err != nil {
+		return
+	}
+	s.shadowWidth = rc.ShadowWidth
+	s.bevelWidth = rc.BevelWidth
+	s.borderWidth = rc.BorderWidth
+	return
+}
+
+// 解析 #rrggbb 颜色
+func parseColor(hex string, alpha int) (color.NRGBA, error) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return color.NRGBA{}, errors.New("颜色格式应为 #rrggbb: " + hex)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, err
+	}
+	if alpha < 0 || alpha > 255 {
+		return color.NRGBA{}, errors.New("透明度应为 0~255")
+	}
+	return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), uint8(alpha)}, nil
+}
+
+// 在背景图 rect 处按遮罩绘制缺口：暗色内嵌，左上内阴影，右下高光
+func drawHole(dst *image.RGBA, rect image.Rectangle, mask *image.Alpha, style renderStyle) {
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			a := maskAt(mask, x, y)
+			if a == 0 {
+				continue
+			}
+			px, py := rect.Min.X+x, rect.Min.Y+y
+			if !(image.Point{px, py}.In(dst.Rect)) {
+				continue
+			}
+
+			blend(dst, px, py, style.hole, a)
+			// 左上方靠近边缘的地方被遮挡，形成内阴影
+			blend(dst, px, py, style.shadow, a*edgeFactor(mask, x, y, -1, -1, style.shadowWidth))
+			// 右下方边缘受光，形成斜面高光
+			blend(dst, px, py, style.bevel, a*edgeFactor(mask, x, y, 1, 1, style.bevelWidth))
+		}
+	}
+}
+
+// 给滑块绘制高光描边，rect 为滑块在 dst 中的位置
+func drawPieceBorder(dst *image.RGBA, rect image.Rectangle, mask *image.Alpha) {
+	if style.borderWidth <= 0 {
+		return
+	}
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			a := maskAt(mask, x, y)
+			if a == 0 {
+				continue
+			}
+			// 任意方向靠近形状外侧即为边缘
+			edge := 0.0
+			for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				edge = math.Max(edge, edgeFactor(mask, x, y, d[0], d[1], style.borderWidth))
+			}
+			blend(dst, rect.Min.X+x, rect.Min.Y+y, style.border, a*edge)
+		}
+	}
+}
+
+// 沿 (dx, dy) 方向 width 像素内靠近形状外侧的程度，越近越接近1
+func edgeFactor(mask *image.Alpha, x, y, dx, dy, width int) float64 {
+	f := 0.0
+	for k := 1; k <= width; k++ {
+		outside := 1 - maskAt(mask, x+dx*k, y+dy*k)
+		f = math.Max(f, outside*float64(width-k+1)/float64(width))
+	}
+	return f
+}
+
+// 遮罩透明度（0~1），超出范围视为形状外
+func maskAt(mask *image.Alpha, x, y int) float64 {
+	if !(image.Point{x, y}.In(mask.Rect)) {
+		return 0
+	}
+	return float64(mask.AlphaAt(x, y).A) / 0xff
+}
+
+// 把颜色 c 按 strength（0~1）叠加到 dst 的 (x, y) 上
+func blend(dst *image.RGBA, x, y int, c color.NRGBA, strength float64) {
+	sa := float64(c.A) / 0xff * strength
+	if sa <= 0 {
+		return
+	}
+	i := dst.PixOffset(x, y)
+	p := dst.Pix[i : i+4 : i+4]
+	p[0] = uint8(float64(c.R)*sa + float64(p[0])*(1-sa))
+	p[1] = uint8(float64(c.G)*sa + float64(p[1])*(1-sa))
+	p[2] = uint8(float64(c.B)*sa + float64(p[2])*(1-sa))
+	// 四舍五入，避免不透明像素的 alpha 被截断成 254
+	p[3] = uint8(0xff*sa + float64(p[3])*(1-sa) + 0.5)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	"github.com/gin-gonic/gin"
+)
+
+// 默认允许的角度误差（度）
+const defaultAngleTolerance = 8
+
+// 生成圆形区域位置与旋转角度，SliderW 为直径，Dx/Dy 为圆形区域左上角
+func setupRotate(slider *SliderInfo) {
+	d := slider.BacH * 4 / 5
+
+	slider.SliderW = d
+	slider.SliderH = d
+	slider.Dx = rand.Intn(slider.BacW - d + 1)
+	slider.Dy = rand.Intn(slider.BacH - d + 1)
+	// 避开接近摆正的角度
+	slider.Angle = 30 + rand.Intn(300)
+}
+
+// 从背景图中裁出圆形区域并按签名中的角度逆时针旋转，圆形外透明
+func rotateImage(img image.Image, slider SliderInfo) image.Image {
+	d := slider.SliderW
+	crop := imaging.Crop(img, image.Rect(slider.Dx, slider.Dy, slider.Dx+d, slider.Dy+d))
+	rotated := imaging.Rotate(crop, float64(slider.Angle), color.Transparent)
+	rotated = imaging.CropCenter(rotated, d, d)
+
+	rgba := image.NewRGBA(image.Rect(0, 0, d, d))
+	mask := circleShape{}.Mask(d, d, nil)
+	draw.DrawMask(rgba, rgba.Bounds(), rotated, image.ZP, mask, image.ZP, draw.Src)
+	drawPieceBorder(rgba, rgba.Bounds(), mask)
+	return rgba
+}
+
+// 校验用户顺时针旋转的角度 angle
+func checkRotate(c *gin.Context, slider SliderInfo) (res checkResult, err error) {
+	angle, err := strconv.ParseFloat(c.PostForm("angle"), 64)
+	if err != nil {
+		err = errors.New("请求参数angle不正确")
+		return
+	}
+
+	// 角度差取 0~180
+	diff := math.Mod(math.Abs(angle-float64(slider.Angle)), 360)
+	diff = math.Min(diff, 360-diff)
+	res.pass = diff <= float64(slider.site().angleTolerance)
+
+	// 旋转拖动条的终点与角度无固定对应关系，不比较终点
+	res.risk, err = trackRisk(c, slider, -1)
+	return
+}
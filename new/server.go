@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 服务默认值（秒）
+const (
+	defaultReadTimeout     = 5
+	defaultWriteTimeout    = 10
+	defaultIdleTimeout     = 15
+	defaultShutdownTimeout = 30
+)
+
+// 是否可以接收流量，关闭前先置为 0，让负载均衡摘除本节点
+var ready int32
+
+// 按配置创建 http 服务，gin 作为 Handler
+func newWebserver(handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         conf.Section.Port,
+		Handler:      handler,
+		ErrorLog:     log.New(logger.WriterLevel(logrus.ErrorLevel), "", 0),
+		ReadTimeout:  time.Duration(conf.Server.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(conf.Server.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(conf.Server.IdleTimeout) * time.Second,
+	}
+}
+
+// 启动服务，收到 SIGTERM 或 SIGINT 后优雅关闭
+func serve(server *http.Server) {
+	done := make(chan struct{})
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
+
+	go gracefulShutdown(server, quit, done)
+
+	atomic.StoreInt32(&ready, 1)
+	logger.WithField("addr", server.Addr).Info("服务已启动")
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.WithError(err).WithField("addr", server.Addr).Error("服务启动失败")
+		return
+	}
+
+	<-done
+	logger.Info("服务已关闭")
+}
+
+// 关闭服务
+// 先标记为未就绪并等待 drainDelay，让负载均衡停止转发，再等待处理中的请求完成
+// 超过 shutdownTimeout 仍未完成的连接直接断开
+// quit: 接收关闭信号
+// done: 发出已经关闭信号
+func gracefulShutdown(server *http.Server, quit <-chan os.Signal, done chan<- struct{}) {
+	sig := <-quit
+	defer close(done)
+
+	atomic.StoreInt32(&ready, 0)
+	logger.WithField("signal", sig.String()).Info("服务正在关闭")
+	time.Sleep(time.Duration(conf.Server.DrainDelay) * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(conf.Server.ShutdownTimeout)*time.Second)
+	defer cancel()
+
+	server.SetKeepAlivesEnabled(false)
+	if err := server.Shutdown(ctx); err != nil {
+		logger.WithError(err).Error("服务未能在限定时间内关闭，强制断开连接")
+		server.Close()
+	}
+}
+
+// 存活检查
+func healthzHandler(c *gin.Context) {
+	responseJson(c, statusSuccess, nil, "ok")
+}
+
+// 就绪检查，关闭过程中返回 503
+func readyzHandler(c *gin.Context) {
+	if atomic.LoadInt32(&ready) == 0 {
+		c.JSON(http.StatusServiceUnavailable, JsonRes{
+			Status:    statusFail,
+			Msg:       "服务正在关闭",
+			TimeStamp: time.Now().Unix(),
+		})
+		return
+	}
+	responseJson(c, statusSuccess, nil, "ok")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// 滑块形状生成器，生成 w×h 的遮罩，形状内不透明、形状外透明
+// 同一个 rnd 种子必须生成相同的形状，滑块图和背景图才能对上
+type ShapeGenerator interface {
+	Mask(w, h int, rnd *rand.Rand) *image.Alpha
+}
+
+// 默认滑块形状
+const defaultShape = "jigsaw"
+
+// 已支持的滑块形状，新增形状实现 ShapeGenerator 后加入此表，启动后只读
+var shapes = map[string]ShapeGenerator{
+	"square": squareShape{},
+	"jigsaw": jigsawShape{},
+	"circle": circleShape{},
+}
+
+// 获取滑块形状，未知形状按方块处理
+func getShape(name string) ShapeGenerator {
+	if g, ok := shapes[name]; ok {
+		return g
+	}
+	return squareShape{}
+}
+
+// 根据签名中的形状和种子生成滑块遮罩
+func sliderMask(slider SliderInfo) *image.Alpha {
+	rnd := rand.New(rand.NewSource(slider.Seed))
+	return getShape(slider.Shape).Mask(slider.SliderW, slider.SliderH, rnd)
+}
+
+// 方块
+type squareShape struct{}
+
+func (squareShape) Mask(w, h int, rnd *rand.Rand) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	for i := range mask.Pix {
+		mask.Pix[i] = 0xff
+	}
+	return mask
+}
+
+// 圆形，旋转验证码也使用该形状
+type circleShape struct{}
+
+func (circleShape) Mask(w, h int, rnd *rand.Rand) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	cx, cy := float64(w)/2, float64(h)/2
+	r := math.Min(cx, cy)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// 边缘一个像素内做抗锯齿
+			d := r - math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+			a := math.Max(0, math.Min(1, d+0.5))
+			mask.SetAlpha(x, y, color.Alpha{uint8(a * 0xff)})
+		}
+	}
+	return mask
+}
+
+// 拼图块：四条边随机凸起或凹陷
+type jigsawShape struct{}
+
+// 拼图块边缘超采样倍数，用于抗锯齿
+const jigsawSamples = 4
+
+func (jigsawShape) Mask(w, h int, rnd *rand.Rand) *image.Alpha {
+	// 四周留出凸起的位置，中间为拼图主体
+	size := math.Min(float64(w), float64(h))
+	margin := size * 0.18
+	radius := margin * 0.7
+
+	left, top := margin, margin
+	right, bottom := float64(w)-margin, float64(h)-margin
+
+	// 每条边的圆心（在边的中点），以及凸起(1)或凹陷(-1)
+	type knob struct {
+		cx, cy float64 // 边中点
+		nx, ny float64 // 向外的法线方向
+		dir    float64
+	}
+	knobs := []knob{
+		{(left + right) / 2, top, 0, -1, 0},
+		{right, (top + bottom) / 2, 1, 0, 0},
+		{(left + right) / 2, bottom, 0, 1, 0},
+		{left, (top + bottom) / 2, -1, 0, 0},
+	}
+	for i := range knobs {
+		knobs[i].dir = 1
+		if rnd.Intn(2) == 0 {
+			knobs[i].dir = -1
+		}
+	}
+
+	inside := func(x, y float64) bool {
+		for _, k := range knobs {
+			// 圆心沿法线偏移，凸起向外、凹陷向内，各边的圆互不重叠
+			cx := k.cx + k.nx*radius*0.4*k.dir
+			cy := k.cy + k.ny*radius*0.4*k.dir
+			if math.Hypot(x-cx, y-cy) < radius {
+				return k.dir > 0
+			}
+		}
+		return x >= left && x < right && y >= top && y < bottom
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	step := 1.0 / jigsawSamples
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			n := 0
+			for sy := 0; sy < jigsawSamples; sy++ {
+				for sx := 0; sx < jigsawSamples; sx++ {
+					if inside(float64(x)+(float64(sx)+0.5)*step, float64(y)+(float64(sy)+0.5)*step) {
+						n++
+					}
+				}
+			}
+			mask.SetAlpha(x, y, color.Alpha{uint8(n * 0xff / (jigsawSamples * jigsawSamples))})
+		}
+	}
+	return mask
+}
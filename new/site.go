@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 未传站点标识时使用的默认站点，配置来自 [Section]
+const defaultSiteKey = "default"
+
+// 站点配置，对应 [Site "站点标识"]，未配置的项沿用 [Section] 与 [Library]
+type siteConfig struct {
+	Secret         string   // 业务后台调用 siteverify 的密钥，各站点不能相同
+	Dir            string   // 背景图目录
+	Tolerance      int      // 验证允许误差（像素）
+	Decoys         *int     // 背景图中干扰缺口数量，0~3，不配置时沿用 [Section]
+	AngleTolerance int      // 旋转验证允许的角度误差（度）
+	Timeout        int      // 验证码有效期（秒）
+	Origin         []string // 允许跨域访问的来源，* 为不限制
+	Type           []string // 允许的验证码类型，为空时不限制
+}
+
+// 站点
+type site struct {
+	key            string
+	secret         string
+	library        *imageLibrary
+	tolerance      int
+	decoys         int
+	angleTolerance int
+	timeout        int
+	origins        []string
+	types          []string
+}
+
+// 示例配置中的占位密钥，公开可见，不能使用
+const placeholderSecret = "change-me"
+
+// 已配置的站点，启动后只读
+var sites map[string]*site
+
+// 按配置建立站点，相同目录的站点共用背景图库
+func loadSites() (err error) {
+	libs := map[string]*imageLibrary{library.dir: library}
+	secrets := make(map[string]string)
+
+	if conf.Section.Secret == placeholderSecret {
+		return errors.New("默认站点密钥（secret）仍为示例值 " + placeholderSecret + "，请修改或删除")
+	}
+
+	sites = make(map[string]*site)
+	sites[defaultSiteKey] = &site{
+		key:            defaultSiteKey,
+		secret:         conf.Section.Secret,
+		library:        library,
+		tolerance:      conf.Section.Tolerance,
+		decoys:         conf.Section.Decoys,
+		angleTolerance: conf.Section.AngleTolerance,
+		timeout:        conf.Section.Timeout,
+		origins:        conf.Section.Origin,
+	}
+	if len(conf.Section.Origin) == 0 {
+		sites[defaultSiteKey].origins = []string{"*"}
+	}
+	secrets[conf.Section.Secret] = defaultSiteKey
+
+	for key, sc := range conf.Site {
+		if key == defaultSiteKey {
+			return errors.New("站点标识不能为 " + defaultSiteKey)
+		}
+		if len(sc.Secret) == 0 {
+			return errors.New("站点没有配置密钥（secret）: " + key)
+		}
+		if sc.Secret == placeholderSecret {
+			return errors.New("站点密钥（secret）仍为示例值: " + key)
+		}
+		if other, ok := secrets[sc.Secret]; ok {
+			return fmt.Errorf("站点 %s 与 %s 的密钥相同", key, other)
+		}
+		secrets[sc.Secret] = key
+
+		s := &site{
+			key:            key,
+			secret:         sc.Secret,
+			library:        library,
+			tolerance:      sc.Tolerance,
+			decoys:         conf.Section.Decoys,
+			angleTolerance: sc.AngleTolerance,
+			timeout:        sc.Timeout,
+			origins:        sc.Origin,
+		}
+		if s.tolerance <= 0 {
+			s.tolerance = conf.Section.Tolerance
+		}
+		if sc.Decoys != nil {
+			s.decoys = *sc.Decoys
+		}
+		if s.angleTolerance <= 0 {
+			s.angleTolerance = conf.Section.AngleTolerance
+		}
+		if s.timeout <= 0 {
+			s.timeout = conf.Section.Timeout
+		}
+		if len(s.origins) == 0 {
+			s.origins = []string{"*"}
+		}
+		for _, t := range sc.Type {
+			if _, ok := challengeTypes[t]; !ok {
+				return fmt.Errorf("站点 %s 配置了不支持的验证码类型: %s", key, t)
+			}
+			s.types = append(s.types, t)
+		}
+
+		if len(sc.Dir) > 0 {
+			dir := resolvePath(sc.Dir)
+			if s.library = libs[dir]; s.library == nil {
+				if s.library, err = newImageLibrary(dir); err != nil {
+					return fmt.Errorf("站点 %s 背景图库加载失败: %v", key, err)
+				}
+				libs[dir] = s.library
+				if conf.Library.Interval > 0 {
+					go s.library.Watch(conf.Library.Interval)
+				}
+			}
+		}
+		sites[key] = s
+	}
+	return
+}
+
+// 按站点标识查找站点，空标识为默认站点
+func getSite(key string) (s *site, ok bool) {
+	if len(key) == 0 {
+		key = defaultSiteKey
+	}
+	s, ok = sites[key]
+	return
+}
+
+// 按 siteverify 密钥查找站点
+func siteBySecret(secret string) (*site, bool) {
+	for _, s := range sites {
+		if len(s.secret) > 0 && subtle.ConstantTimeCompare([]byte(secret), []byte(s.secret)) == 1 {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// 验证码所属站点，旧签名或站点已下线的按默认站点处理
+func (s SliderInfo) site() *site {
+	if st, ok := getSite(s.Site); ok {
+		return st
+	}
+	return sites[defaultSiteKey]
+}
+
+// 站点是否允许该验证码类型
+func (s *site) allowType(t string) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, v := range s.types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// 站点默认的验证码类型
+func (s *site) defaultType() string {
+	if s.allowType(typeSlider) {
+		return typeSlider
+	}
+	return s.types[0]
+}
+
+// 站点是否允许该来源跨域访问
+func (s *site) allowOrigin(origin string) bool {
+	for _, o := range s.origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// 校验请求来源，没有 Origin 请求头的请求（非浏览器跨域）不限制
+func checkOrigin(c *gin.Context, s *site) bool {
+	origin := c.GetHeader("Origin")
+	return len(origin) == 0 || s.allowOrigin(origin)
+}
+
+// 任一站点允许的来源都可以通过跨域预检，具体站点在接口中校验
+// 只有被某个站点明确列出的来源才允许携带凭据，* 不允许
+func anySiteAllowOrigin(origin string) (allowed, credentials bool) {
+	for _, s := range sites {
+		if s.allowOrigin(origin) {
+			allowed = true
+		}
+		for _, o := range s.origins {
+			if o != "*" && strings.EqualFold(o, origin) {
+				return true, true
+			}
+		}
+	}
+	return
+}
+
+// 站点验证码有效期
+func (s *site) ttl() time.Duration {
+	return time.Duration(s.timeout) * time.Second
+}
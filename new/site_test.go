@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/gcfg.v1"
+)
+
+// 站点未配置的项沿用 [Section]，配置为 0 的干扰缺口数量不被覆盖
+func TestLoadSitesDecoys(t *testing.T) {
+	oldConf, oldLibrary, oldSites := conf, library, sites
+	t.Cleanup(func() { conf, library, sites = oldConf, oldLibrary, oldSites })
+	library = &imageLibrary{dir: t.TempDir()}
+
+	conf = config{}
+	err := gcfg.ReadStringInto(&conf, `
+[Section]
+tolerance = 5
+decoys = 2
+
+[Site "off"]
+secret = off-secret
+decoys = 0
+
+[Site "inherit"]
+secret = inherit-secret
+tolerance = 3
+`)
+	mustNil(t, err)
+	mustNil(t, loadSites())
+
+	tests := []struct {
+		site      string
+		decoys    int
+		tolerance int
+	}{
+		{defaultSiteKey, 2, 5},
+		{"off", 0, 5},
+		{"inherit", 2, 3},
+	}
+	for _, tt := range tests {
+		s, ok := getSite(tt.site)
+		if !ok {
+			t.Fatalf("site %s not loaded", tt.site)
+		}
+		if s.decoys != tt.decoys || s.tolerance != tt.tolerance {
+			t.Errorf("site %s: decoys = %d, tolerance = %d, want %d, %d", tt.site, s.decoys, s.tolerance, tt.decoys, tt.tolerance)
+		}
+	}
+}
+
+// 示例占位密钥不能启用 siteverify
+func TestLoadSitesRejectsPlaceholderSecret(t *testing.T) {
+	oldConf, oldLibrary, oldSites := conf, library, sites
+	t.Cleanup(func() { conf, library, sites = oldConf, oldLibrary, oldSites })
+	library = &imageLibrary{dir: t.TempDir()}
+
+	for _, ini := range []string{
+		"[Section]\nsecret = change-me\n",
+		"[Section]\nsecret = real\n[Site \"shop\"]\nsecret = change-me\n",
+	} {
+		conf = config{}
+		mustNil(t, gcfg.ReadStringInto(&conf, ini))
+		if err := loadSites(); err == nil {
+			t.Errorf("placeholder secret accepted:\n%s", ini)
+		}
+	}
+}
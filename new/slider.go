@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"math/rand"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 生成滑块尺寸、缺口位置、形状以及干扰缺口
+func setupSlider(slider *SliderInfo) {
+
+	// 获取滑块尺寸
+	sliderInt := getSliderSize(slider.BacW)
+
+	// 获取滑块位置
+	dx := rand.Intn(slider.BacW-2*sliderInt) + sliderInt
+	dy := rand.Intn(slider.BacH - sliderInt)
+
+	slider.SliderW = sliderInt        // 滑块宽度
+	slider.SliderH = sliderInt        // 滑块高度
+	slider.Dx = dx                    // 滑块位置x坐标
+	slider.Dy = dy                    // 滑块位置y坐标
+	slider.Shape = conf.Section.Shape // 滑块形状
+	slider.Seed = rand.Int63()        // 形状随机种子
+
+	// 干扰缺口
+	slider.Decoys = placeDecoys(slider.site().decoys, slider.BacW, slider.BacH, sliderInt, dx, dy)
+}
+
+// 滑块图片
+func sliderPiece(img image.Image, slider SliderInfo) image.Image {
+
+	// 滑块形状，形状外透明
+	mask := sliderMask(slider)
+
+	if slider.Strip {
+		// 返回与背景同高的透明长条，滑块画在 Dy 处，前端无需知道坐标
+		rgba := image.NewRGBA(image.Rect(0, 0, slider.SliderW, slider.BacH))
+		pieceRect := image.Rect(0, slider.Dy, slider.SliderW, slider.Dy+slider.SliderH)
+		draw.DrawMask(rgba, pieceRect, img, image.Pt(slider.Dx, slider.Dy), mask, image.ZP, draw.Src)
+		drawPieceBorder(rgba, pieceRect, mask)
+		return rgba
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, slider.SliderW, slider.SliderH))
+	draw.DrawMask(rgba, rgba.Bounds(), img, image.Pt(slider.Dx, slider.Dy), mask, image.ZP, draw.Src)
+	drawPieceBorder(rgba, rgba.Bounds(), mask)
+	return rgba
+}
+
+// 带缺口的背景图片
+func sliderBackground(img image.Image, slider SliderInfo) image.Image {
+
+	// 缺口按滑块形状绘制
+	mask := sliderMask(slider)
+
+	// 绘图的背景图。
+	dist := image.NewRGBA(image.Rect(0, 0, slider.BacW, slider.BacH))
+	siiderRect := image.Rect(slider.Dx, slider.Dy, slider.Dx+slider.SliderW, slider.Dy+slider.SliderH)
+
+	draw.Draw(dist, dist.Bounds(), img, image.ZP, draw.Src)
+	drawDecoys(dist, slider)
+	drawHole(dist, siiderRect, mask, style)
+	return dist
+}
+
+// 校验滑块最终位置 x，y 为可选参数
+func checkSlider(c *gin.Context, slider SliderInfo) (res checkResult, err error) {
+
+	// 获取滑块最终位置
+	x, err := strconv.Atoi(c.PostForm("x"))
+	if err != nil {
+		err = errors.New("请求参数x不正确")
+		return
+	}
+
+	res.pass = abs(x-slider.Dx) <= slider.site().tolerance
+
+	// y 为可选参数
+	y := -1
+	if ry := c.PostForm("y"); len(ry) > 0 {
+		y, err = strconv.Atoi(ry)
+		if err != nil {
+			err = errors.New("请求参数y不正确")
+			return
+		}
+		res.pass = res.pass && abs(y-slider.Dy) <= slider.site().tolerance
+	}
+
+	// 分析拖动轨迹
+	res.risk, err = trackRisk(c, slider, x)
+	if err != nil {
+		return
+	}
+
+	// 拖到干扰缺口上基本可以判定为识别缺口的脚本，直接作废
+	if !res.pass && hitDecoy(slider, x, y) {
+		res.bot = true
+		sliderLog(c, slider).WithField("ip", c.ClientIP()).Warn("命中干扰缺口")
+	}
+	return
+}
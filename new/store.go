@@ -0,0 +1,195 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// 验证码状态错误
+var (
+	errNotFound = errors.New("验证码不存在")
+	errConsumed = errors.New("验证码已失效")
+	errNoPass   = errors.New("验证凭证不存在或已使用")
+)
+
+// 验证通过凭证信息，供业务后台核验
+type PassInfo struct {
+	Id   string `json:"id"`   // 验证码ID
+	Type string `json:"type"` // 验证码类型
+	Ip   string `json:"ip"`   // 用户IP
+	Time int64  `json:"time"` // 验证通过时间
+	Site string `json:"site"` // 站点标识
+}
+
+// 验证码状态存储，记录验证次数以及是否已使用，防止重放
+type ChallengeStore interface {
+	// 登记新的验证码
+	Add(id string, ttl time.Duration) error
+	// 检查验证码是否仍可使用
+	Check(id string) error
+	// 记录一次验证，返回累计验证次数，已达到 max 次时不再计数，返回 errConsumed
+	Attempt(id string, max int) (int, error)
+	// 标记验证码已使用，已使用过的返回 errConsumed
+	Consume(id string) error
+	// 保存验证通过凭证
+	SavePass(token string, pass PassInfo, ttl time.Duration) error
+	// 取出验证通过凭证，取出后即失效
+	TakePass(token string) (PassInfo, error)
+}
+
+// 全局验证码存储
+var store ChallengeStore
+
+// 根据配置创建验证码存储
+func newChallengeStore() (ChallengeStore, error) {
+	switch conf.Store.Type {
+	case "", "memory":
+		return newMemoryStore(time.Minute), nil
+	case "redis":
+		return newRedisStore(newRedisClient())
+	default:
+		return nil, errors.New("不支持的存储类型: " + conf.Store.Type)
+	}
+}
+
+// 生成验证码ID
+func newChallengeID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// 单个验证码状态
+type challengeState struct {
+	attempts int
+	consumed bool
+	expire   time.Time
+}
+
+// 单个验证通过凭证
+type passState struct {
+	pass   PassInfo
+	expire time.Time
+}
+
+// 内存存储，适用于单机部署
+type memoryStore struct {
+	mu     sync.Mutex
+	items  map[string]*challengeState
+	passes map[string]passState
+}
+
+// 创建内存存储，并定期清理过期数据
+func newMemoryStore(cleanup time.Duration) *memoryStore {
+	m := &memoryStore{
+		items:  make(map[string]*challengeState),
+		passes: make(map[string]passState),
+	}
+	go func() {
+		for range time.Tick(cleanup) {
+			m.purge()
+		}
+	}()
+	return m
+}
+
+func (m *memoryStore) Add(id string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.items[id] = &challengeState{expire: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *memoryStore) Check(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.get(id)
+	return err
+}
+
+func (m *memoryStore) Attempt(id string, max int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.get(id)
+	if err != nil {
+		return 0, err
+	}
+	if item.attempts >= max {
+		return 0, errConsumed
+	}
+	item.attempts++
+	return item.attempts, nil
+}
+
+func (m *memoryStore) Consume(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	item.consumed = true
+	return nil
+}
+
+func (m *memoryStore) SavePass(token string, pass PassInfo, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.passes[token] = passState{pass: pass, expire: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *memoryStore) TakePass(token string) (PassInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.passes[token]
+	if !ok {
+		return PassInfo{}, errNoPass
+	}
+	delete(m.passes, token)
+	if time.Now().After(item.expire) {
+		return PassInfo{}, errNoPass
+	}
+	return item.pass, nil
+}
+
+// 获取可用的验证码状态，调用方需持有锁
+func (m *memoryStore) get(id string) (*challengeState, error) {
+	item, ok := m.items[id]
+	if !ok || time.Now().After(item.expire) {
+		return nil, errNotFound
+	}
+	if item.consumed {
+		return nil, errConsumed
+	}
+	return item, nil
+}
+
+// 清理过期数据
+func (m *memoryStore) purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, item := range m.items {
+		if now.After(item.expire) {
+			delete(m.items, id)
+		}
+	}
+	for token, item := range m.passes {
+		if now.After(item.expire) {
+			delete(m.passes, token)
+		}
+	}
+}
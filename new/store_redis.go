@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// redis key 前缀
+const (
+	redisChallengePrefix = "slider:challenge:" // 验证码状态
+	redisPassPrefix      = "slider:pass:"      // 验证通过凭证
+)
+
+// 验证码不存在返回 -1，已使用返回 -2
+var (
+	// 记录一次验证，ARGV[1] 为最多验证次数，已达到时按已使用处理
+	attemptScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then return -1 end
+if redis.call("HGET", KEYS[1], "consumed") == "1" then return -2 end
+if tonumber(redis.call("HGET", KEYS[1], "attempts") or "0") >= tonumber(ARGV[1]) then return -2 end
+return redis.call("HINCRBY", KEYS[1], "attempts", 1)
+`)
+	// 标记已使用
+	consumeScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then return -1 end
+if redis.call("HGET", KEYS[1], "consumed") == "1" then return -2 end
+redis.call("HSET", KEYS[1], "consumed", "1")
+return 0
+`)
+)
+
+// redis 存储，适用于多机部署
+type redisStore struct {
+	client *redis.Client
+}
+
+// 根据配置创建 redis 客户端
+func newRedisClient() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     conf.Redis.Addr,
+		Password: conf.Redis.Password,
+		DB:       conf.Redis.DB,
+	})
+}
+
+// 创建 redis 存储，并检查连接
+func newRedisStore(client *redis.Client) (*redisStore, error) {
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+	return &redisStore{client: client}, nil
+}
+
+func (r *redisStore) Add(id string, ttl time.Duration) error {
+	ctx := context.Background()
+	key := redisChallengePrefix + id
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "attempts", 0, "consumed", 0)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+func (r *redisStore) Check(id string) error {
+	consumed, err := r.client.HGet(context.Background(), redisChallengePrefix+id, "consumed").Result()
+	if err == redis.Nil {
+		return errNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if consumed == "1" {
+		return errConsumed
+	}
+	return nil
+}
+
+func (r *redisStore) Attempt(id string, max int) (int, error) {
+	n, err := attemptScript.Run(context.Background(), r.client, []string{redisChallengePrefix + id}, max).Int()
+	if err != nil {
+		return 0, err
+	}
+	if err = scriptError(n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (r *redisStore) Consume(id string) error {
+	n, err := consumeScript.Run(context.Background(), r.client, []string{redisChallengePrefix + id}).Int()
+	if err != nil {
+		return err
+	}
+	return scriptError(n)
+}
+
+func (r *redisStore) SavePass(token string, pass PassInfo, ttl time.Duration) error {
+	data, err := json.Marshal(pass)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(context.Background(), redisPassPrefix+token, data, ttl).Err()
+}
+
+func (r *redisStore) TakePass(token string) (PassInfo, error) {
+	ctx := context.Background()
+	key := redisPassPrefix + token
+
+	// 读取后立即删除，保证凭证只能核验一次
+	var get *redis.StringCmd
+	var del *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		del = pipe.Del(ctx, key)
+		return nil
+	})
+	if err == redis.Nil || (err == nil && del.Val() == 0) {
+		return PassInfo{}, errNoPass
+	}
+	if err != nil {
+		return PassInfo{}, err
+	}
+
+	pass := PassInfo{}
+	err = json.Unmarshal([]byte(get.Val()), &pass)
+	return pass, err
+}
+
+// 转换脚本返回的状态
+func scriptError(n int) error {
+	switch n {
+	case -1:
+		return errNotFound
+	case -2:
+		return errConsumed
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// 测试用的验证码存储，advance 让存储内的时间前进
+// redis 存储基于 miniredis，过期时间最小精度为1秒
+func newTestStore(t *testing.T, kind string) (ChallengeStore, func(time.Duration)) {
+	if kind == "memory" {
+		return newMemoryStore(time.Minute), time.Sleep
+	}
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mr.Close)
+	rs, err := newRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs, mr.FastForward
+}
+
+func TestChallengeStore(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s ChallengeStore, advance func(time.Duration))
+	}{
+		{"add then check", func(t *testing.T, s ChallengeStore, advance func(time.Duration)) {
+			mustNil(t, s.Add("a", time.Minute))
+			mustNil(t, s.Check("a"))
+		}},
+		{"check unknown", func(t *testing.T, s ChallengeStore, advance func(time.Duration)) {
+			mustErr(t, s.Check("missing"), errNotFound)
+			_, err := s.Attempt("missing", 3)
+			mustErr(t, err, errNotFound)
+			mustErr(t, s.Consume("missing"), errNotFound)
+		}},
+		{"attempts count up", func(t *testing.T, s ChallengeStore, advance func(time.Duration)) {
+			mustNil(t, s.Add("a", time.Minute))
+			for want := 1; want <= 3; want++ {
+				n, err := s.Attempt("a", 3)
+				mustNil(t, err)
+				if n != want {
+					t.Fatalf("attempt = %d, want %d", n, want)
+				}
+			}
+		}},
+		{"consume once", func(t *testing.T, s ChallengeStore, advance func(time.Duration)) {
+			mustNil(t, s.Add("a", time.Minute))
+			mustNil(t, s.Consume("a"))
+			mustErr(t, s.Check("a"), errConsumed)
+			_, err := s.Attempt("a", 3)
+			mustErr(t, err, errConsumed)
+		}},
+		{"attempts limited", func(t *testing.T, s ChallengeStore, advance func(time.Duration)) {
+			mustNil(t, s.Add("a", time.Minute))
+			for i := 0; i < 3; i++ {
+				_, err := s.Attempt("a", 3)
+				mustNil(t, err)
+			}
+			for i := 0; i < 5; i++ {
+				_, err := s.Attempt("a", 3)
+				mustErr(t, err, errConsumed)
+			}
+		}},
+		{"double consume", func(t *testing.T, s ChallengeStore, advance func(time.Duration)) {
+			mustNil(t, s.Add("a", time.Minute))
+			mustNil(t, s.Consume("a"))
+			mustErr(t, s.Consume("a"), errConsumed)
+		}},
+		{"expiry", func(t *testing.T, s ChallengeStore, advance func(time.Duration)) {
+			mustNil(t, s.Add("a", time.Second))
+			advance(1100 * time.Millisecond)
+			mustErr(t, s.Check("a"), errNotFound)
+			mustErr(t, s.Consume("a"), errNotFound)
+		}},
+		{"take pass once", func(t *testing.T, s ChallengeStore, advance func(time.Duration)) {
+			pass := PassInfo{Id: "a", Type: typeSlider, Ip: "10.0.0.1", Time: 1, Site: "shop"}
+			mustNil(t, s.SavePass("t", pass, time.Minute))
+			got, err := s.TakePass("t")
+			mustNil(t, err)
+			if got != pass {
+				t.Fatalf("pass = %+v, want %+v", got, pass)
+			}
+			_, err = s.TakePass("t")
+			mustErr(t, err, errNoPass)
+		}},
+		{"take pass unknown", func(t *testing.T, s ChallengeStore, advance func(time.Duration)) {
+			_, err := s.TakePass("missing")
+			mustErr(t, err, errNoPass)
+		}},
+		{"take pass expired", func(t *testing.T, s ChallengeStore, advance func(time.Duration)) {
+			mustNil(t, s.SavePass("t", PassInfo{Id: "a"}, time.Second))
+			advance(1100 * time.Millisecond)
+			_, err := s.TakePass("t")
+			mustErr(t, err, errNoPass)
+		}},
+	}
+
+	for _, kind := range []string{"memory", "redis"} {
+		for _, tt := range tests {
+			kind, tt := kind, tt
+			t.Run(kind+"/"+tt.name, func(t *testing.T) {
+				s, advance := newTestStore(t, kind)
+				tt.run(t, s, advance)
+			})
+		}
+	}
+}
+
+func mustNil(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func mustErr(t *testing.T, err, want error) {
+	t.Helper()
+	if err != want {
+		t.Fatalf("error = %v, want %v", err, want)
+	}
+}
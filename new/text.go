@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// 默认字符，去掉了 0/O、1/I 等容易混淆的字符
+const defaultTextChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// 字符验证码的字体与字符，启动时加载
+var (
+	textFonts []*truetype.Font
+	textChars []rune
+)
+
+// 加载字符验证码字体，未配置时使用内置的 Go 字体
+func loadTextFonts() error {
+	var fonts []*truetype.Font
+	for _, path := range conf.Text.Font {
+		f, err := loadFont(path)
+		if err != nil {
+			return err
+		}
+		fonts = append(fonts, f)
+	}
+	if len(fonts) == 0 {
+		for _, ttf := range [][]byte{goregular.TTF, gobold.TTF, gomonobold.TTF} {
+			f, err := truetype.Parse(ttf)
+			if err != nil {
+				return err
+			}
+			fonts = append(fonts, f)
+		}
+	}
+
+	chars := conf.Text.Chars
+	if len(chars) == 0 {
+		chars = defaultTextChars
+	}
+	// 只保留所有字体中都有字形的字符
+	runes := []rune(chars)
+	for _, f := range fonts {
+		runes = glyphsInFont(f, string(runes))
+	}
+	if len(runes) == 0 {
+		return errors.New("字体中没有可用字符")
+	}
+
+	textFonts = fonts
+	textChars = runes
+	return nil
+}
+
+// 随机生成答案，答案只保存在加密的签名中
+func setupText(slider *SliderInfo) {
+	n := conf.Text.MinLength
+	if conf.Text.MaxLength > n {
+		n += rand.Intn(conf.Text.MaxLength - n + 1)
+	}
+
+	answer := make([]rune, n)
+	for i := range answer {
+		answer[i] = textChars[rand.Intn(len(textChars))]
+	}
+	slider.Answer = string(answer)
+	slider.Seed = rand.Int63()
+}
+
+// 绘制扭曲的字符、干扰线与噪点，同一签名每次绘制结果相同
+func textImage(img image.Image, slider SliderInfo) image.Image {
+	rnd := rand.New(rand.NewSource(slider.Seed))
+	w, h := slider.BacW, slider.BacH
+
+	canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+	bg := color.NRGBA{uint8(225 + rnd.Intn(30)), uint8(225 + rnd.Intn(30)), uint8(225 + rnd.Intn(30)), 0xff}
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(bg), image.ZP, draw.Src)
+
+	// 逐个绘制字符，随机字体、大小、角度与颜色
+	answer := []rune(slider.Answer)
+	cell := w / (len(answer) + 1)
+	for i, r := range answer {
+		size := int(math.Min(float64(h)*0.75, float64(cell)*1.3)) * (85 + rnd.Intn(30)) / 100
+		ink := randomInk(rnd)
+		glyph := renderGlyph(textFonts[rnd.Intn(len(textFonts))], r, size, ink, float64(rnd.Intn(61)-30))
+
+		b := glyph.Bounds()
+		cx := cell/2 + i*cell + cell/2 + rnd.Intn(cell/4+1) - cell/8
+		cy := h/2 + rnd.Intn(h/6+1) - h/12
+		rect := image.Rect(cx-b.Dx()/2, cy-b.Dy()/2, cx-b.Dx()/2+b.Dx(), cy-b.Dy()/2+b.Dy())
+		draw.Draw(canvas, rect, glyph, b.Min, draw.Over)
+	}
+
+	// 正弦扭曲
+	out := warp(canvas, rnd)
+
+	// 干扰线
+	for i := 0; i < conf.Text.NoiseLines; i++ {
+		drawWave(out, rnd, randomInk(rnd))
+	}
+
+	// 噪点
+	for i := 0; i < w*h/30; i++ {
+		out.Set(rnd.Intn(w), rnd.Intn(h), randomInk(rnd))
+	}
+	return out
+}
+
+// 随机深色
+func randomInk(rnd *rand.Rand) color.NRGBA {
+	return color.NRGBA{uint8(rnd.Intn(150)), uint8(rnd.Intn(150)), uint8(rnd.Intn(150)), 0xff}
+}
+
+// 按正弦曲线横向、纵向错位像素
+func warp(src *image.RGBA, rnd *rand.Rand) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+
+	ax := float64(b.Dy()) / 12
+	ay := float64(b.Dy()) / 10
+	px := float64(b.Dy()) * (0.8 + rnd.Float64()*0.6)
+	py := float64(b.Dx()) * (0.4 + rnd.Float64()*0.4)
+	phx, phy := rnd.Float64()*2*math.Pi, rnd.Float64()*2*math.Pi
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			sx := x + int(ax*math.Sin(2*math.Pi*float64(y)/px+phx))
+			sy := y + int(ay*math.Sin(2*math.Pi*float64(x)/py+phy))
+			if !(image.Point{sx, sy}.In(b)) {
+				sx, sy = x, y
+			}
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+	return dst
+}
+
+// 横穿图片的正弦干扰线
+func drawWave(dst *image.RGBA, rnd *rand.Rand, ink color.NRGBA) {
+	b := dst.Bounds()
+	amp := float64(b.Dy()) * (0.1 + rnd.Float64()*0.25)
+	period := float64(b.Dx()) * (0.5 + rnd.Float64())
+	phase := rnd.Float64() * 2 * math.Pi
+	base := float64(b.Dy()) * (0.25 + rnd.Float64()*0.5)
+	thick := 1 + rnd.Intn(2)
+
+	for x := b.Min.X; x < b.Max.X; x++ {
+		y := int(base + amp*math.Sin(2*math.Pi*float64(x)/period+phase))
+		for t := 0; t < thick; t++ {
+			dst.Set(x, y+t, ink)
+		}
+	}
+}
+
+// 校验用户输入的字符，不区分大小写
+func checkText(c *gin.Context, slider SliderInfo) (res checkResult, err error) {
+	answer := strings.TrimSpace(c.PostForm("answer"))
+	if len(answer) == 0 {
+		err = errors.New("请求参数answer不正确")
+		return
+	}
+	res.pass = strings.EqualFold(answer, slider.Answer)
+	return
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// 签名版本号，写在密文第一个字节
+const (
+	tokenVersionGCM   byte = 2 // 版本号 + nonce + 密文
+	tokenVersionKeyId byte = 3 // 版本号 + 密钥ID长度 + 密钥ID + nonce + 密文
+)
+
+// 旧版 CBC 签名使用的内置密钥，仅在兼容期内用于解密
+const legacyCbcKey = "ABCDEFGHIJKLMNO1"
+
+// 旧版 CBC 签名兼容期：升级时间与截止时间，零值为不接受
+var cbcSince, cbcDeadline time.Time
+
+// 根据配置计算旧版 CBC 签名兼容期截止时间，以配置的升级时间为起点，不受重启影响
+func loadCbcDeadline() error {
+	cbcSince, cbcDeadline = time.Time{}, time.Time{}
+	if conf.Section.CbcGrace <= 0 {
+		return nil
+	}
+	if len(conf.Section.CbcSince) == 0 {
+		return errors.New("开启 cbcGrace 时必须配置升级时间 cbcSince")
+	}
+	since, err := time.Parse(time.RFC3339, conf.Section.CbcSince)
+	if err != nil {
+		return err
+	}
+	cbcSince = since
+	cbcDeadline = since.Add(time.Duration(conf.Section.CbcGrace) * time.Second)
+	return nil
+}
+
+// 使用主密钥加密生成签名，签名中带上密钥ID
+func sealToken(plain []byte) (string, error) {
+	id, key := currentKeyring().primary()
+
+	crypted, err := AesEncryptGCM(plain, key)
+	if err != nil {
+		return "", err
+	}
+	token := make([]byte, 0, 2+len(id)+len(crypted))
+	token = append(token, tokenVersionKeyId, byte(len(id)))
+	token = append(token, id...)
+	token = append(token, crypted...)
+	return base64.StdEncoding.EncodeToString(token), nil
+}
+
+// 解密签名，按密钥ID选择密钥，兼容期内仍接受旧版 CBC 签名
+func openToken(sign string) ([]byte, error) {
+	token, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return nil, err
+	}
+	kr := currentKeyring()
+
+	if len(token) > 1 && token[0] == tokenVersionKeyId {
+		n := int(token[1])
+		if len(token) > 2+n {
+			if key, ok := kr.keys[string(token[2:2+n])]; ok {
+				if plain, err := AesDecryptGCM(token[2+n:], key); err == nil {
+					return plain, nil
+				}
+			}
+		}
+	}
+
+	// 没有密钥ID的签名逐个密钥尝试
+	if len(token) > 0 && token[0] == tokenVersionGCM {
+		for _, id := range kr.ids() {
+			if plain, err := AesDecryptGCM(token[1:], kr.keys[id]); err == nil {
+				return plain, nil
+			}
+		}
+	}
+
+	// 旧版签名没有版本号，只在兼容期内用旧版内置密钥解密，不使用密钥环中的密钥
+	if time.Now().Before(cbcDeadline) {
+		if plain := AesDecryptCBC(sign, legacyCbcKey); len(plain) > 0 {
+			return []byte(plain), nil
+		}
+	}
+	return nil, errors.New("签名校验失败")
+}
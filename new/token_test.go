@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// 测试用密钥环：主密钥 2，旧密钥 1
+func useTestKeyring(t *testing.T) {
+	old := ring.Load()
+	ring.Store(&keyring{primaryId: "2", keys: map[string][]byte{
+		"1": []byte("0123456789abcdef"),
+		"2": []byte("fedcba9876543210"),
+	}})
+	t.Cleanup(func() {
+		if old != nil {
+			ring.Store(old)
+		}
+	})
+}
+
+// 设置旧版 CBC 签名兼容期截止时间
+func useCbcDeadline(t *testing.T, deadline time.Time) {
+	old := cbcDeadline
+	cbcDeadline = deadline
+	t.Cleanup(func() { cbcDeadline = old })
+}
+
+func TestOpenToken(t *testing.T) {
+	useTestKeyring(t)
+	plain := []byte(`{"BacW":400}`)
+
+	sealed, err := sealToken(plain)
+	mustNil(t, err)
+
+	// 旧密钥加密、带密钥ID的签名
+	crypted, err := AesEncryptGCM(plain, currentKeyring().keys["1"])
+	mustNil(t, err)
+	oldKeyId := base64.StdEncoding.EncodeToString(append([]byte{tokenVersionKeyId, 1, '1'}, crypted...))
+	unknownId := base64.StdEncoding.EncodeToString(append([]byte{tokenVersionKeyId, 1, '9'}, crypted...))
+
+	// 没有密钥ID的 GCM 签名
+	noKeyId := base64.StdEncoding.EncodeToString(append([]byte{tokenVersionGCM}, crypted...))
+
+	// 篡改最后一个字节
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	raw[len(raw)-1] ^= 1
+	tampered := base64.StdEncoding.EncodeToString(raw)
+
+	// 旧版 CBC 签名，以及用密钥环中的密钥生成的 CBC 签名
+	legacy := AesEncryptCBC(string(plain), legacyCbcKey)
+	ringCbc := AesEncryptCBC(string(plain), string(currentKeyring().keys["2"]))
+
+	tests := []struct {
+		name     string
+		sign     string
+		deadline time.Time
+		ok       bool
+	}{
+		{"primary key", sealed, time.Time{}, true},
+		{"old key id", oldKeyId, time.Time{}, true},
+		{"unknown key id", unknownId, time.Time{}, false},
+		{"gcm without key id", noKeyId, time.Time{}, true},
+		{"tampered", tampered, time.Time{}, false},
+		{"not base64", "!!!", time.Time{}, false},
+		{"cbc without grace", legacy, time.Time{}, false},
+		{"cbc within grace", legacy, time.Now().Add(time.Minute), true},
+		{"cbc after grace", legacy, time.Now().Add(-time.Minute), false},
+		{"cbc with keyring key", ringCbc, time.Now().Add(time.Minute), false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			useCbcDeadline(t, tt.deadline)
+			got, err := openToken(tt.sign)
+			if !tt.ok {
+				// 用错误密钥解密 CBC 不一定报错，但得不到原文
+				if err == nil && string(got) == string(plain) {
+					t.Fatal("openToken accepted the token")
+				}
+				return
+			}
+			mustNil(t, err)
+			if string(got) != string(plain) {
+				t.Fatalf("plain = %q, want %q", got, plain)
+			}
+		})
+	}
+}
+
+func TestLoadCbcDeadline(t *testing.T) {
+	old := conf.Section
+	t.Cleanup(func() {
+		conf.Section = old
+		cbcDeadline = time.Time{}
+	})
+
+	tests := []struct {
+		name  string
+		grace int
+		since string
+		want  time.Time
+		ok    bool
+	}{
+		{"disabled", 0, "", time.Time{}, true},
+		{"anchored", 600, "2026-10-16T00:00:00Z", time.Date(2026, 10, 16, 0, 10, 0, 0, time.UTC), true},
+		{"missing since", 600, "", time.Time{}, false},
+		{"bad since", 600, "yesterday", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		conf.Section.CbcGrace = tt.grace
+		conf.Section.CbcSince = tt.since
+		err := loadCbcDeadline()
+		if (err == nil) != tt.ok {
+			t.Fatalf("%s: error = %v", tt.name, err)
+		}
+		if tt.ok && !cbcDeadline.Equal(tt.want) {
+			t.Fatalf("%s: deadline = %v, want %v", tt.name, cbcDeadline, tt.want)
+		}
+	}
+}
+
+// 旧版签名没有验证码ID，兼容期内可以使用，但只能验证通过一次
+func TestDecodeLegacySliderInfo(t *testing.T) {
+	useLegacyEnv(t)
+	sign := legacySign(t, legacySlider())
+
+	slider, err := decodeSliderInfo(sign)
+	mustNil(t, err)
+	if slider.Id != legacyChallengeID(sign) {
+		t.Fatalf("id = %q, want %q", slider.Id, legacyChallengeID(sign))
+	}
+	_, err = decodeSliderInfo(sign)
+	mustNil(t, err)
+
+	mustNil(t, store.Consume(slider.Id))
+	_, err = decodeSliderInfo(sign)
+	mustErr(t, err, errConsumed)
+}
+
+// 旧版密钥是公开的，伪造的非旧版内容一律拒绝
+func TestDecodeLegacySliderInfoRejectsForged(t *testing.T) {
+	useLegacyEnv(t)
+
+	tests := []struct {
+		name   string
+		modify func(s *SliderInfo)
+	}{
+		{"issued after upgrade", func(s *SliderInfo) { s.Time = cbcSince.Unix() + 1 }},
+		{"huge piece", func(s *SliderInfo) { s.SliderW, s.SliderH = 5000, 5000 }},
+		{"click type", func(s *SliderInfo) { s.Type, s.Targets = typeClick, 5 }},
+		{"text answer", func(s *SliderInfo) { s.Answer = "ABCD" }},
+		{"decoys", func(s *SliderInfo) { s.Decoys = []Decoy{{X: 1, Y: 1, W: 50}} }},
+		{"dx outside background", func(s *SliderInfo) { s.Dx = 390 }},
+		{"dy outside background", func(s *SliderInfo) { s.Dy = -1 }},
+		{"arbitrary src", func(s *SliderInfo) { s.Src = "/etc/passwd" }},
+	}
+	for _, tt := range tests {
+		s := legacySlider()
+		tt.modify(&s)
+		if _, err := decodeSliderInfo(legacySign(t, s)); err == nil {
+			t.Errorf("%s: forged legacy token accepted", tt.name)
+		}
+	}
+}
+
+// 旧版签名测试环境：兼容期内，默认站点图库中有一张背景图
+func useLegacyEnv(t *testing.T) {
+	useTestKeyring(t)
+	useCbcDeadline(t, time.Now().Add(time.Minute))
+	oldSince, oldStore, oldSites := cbcSince, store, sites
+	t.Cleanup(func() { cbcSince, store, sites = oldSince, oldStore, oldSites })
+
+	cbcSince = time.Now().Add(-time.Second)
+	store = newMemoryStore(time.Minute)
+	lib := &imageLibrary{dir: "img"}
+	lib.files.Store([]string{"img/1.png"})
+	sites = map[string]*site{defaultSiteKey: {key: defaultSiteKey, timeout: 120, library: lib}}
+}
+
+// 旧版 getCode 生成的内容
+func legacySlider() SliderInfo {
+	return SliderInfo{BacW: 400, BacH: 200, SliderW: 50, SliderH: 50, Dx: 120, Dy: 40, Src: "img/1.png", Time: cbcSince.Unix() - 10}
+}
+
+func legacySign(t *testing.T, s SliderInfo) string {
+	plain, err := json.Marshal(s)
+	mustNil(t, err)
+	return AesEncryptCBC(string(plain), legacyCbcKey)
+}
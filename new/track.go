@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+// 拖动轨迹采样点：相对时间（毫秒）与坐标
+type trackPoint struct {
+	T float64
+	X float64
+	Y float64
+}
+
+// 轨迹风险分值，各项特征命中后累加，最高为1
+// 除终点不符外，每项单独命中即达到默认风险阈值 0.7
+const (
+	riskTooFew      = 0.8 // 采样点过少
+	riskDuration    = 0.8 // 拖动时长不合理
+	riskConstSpeed  = 0.8 // 速度恒定
+	riskNoJitter    = 0.8 // 纵向没有抖动
+	riskLinear      = 0.8 // 轨迹为直线
+	riskEndMismatch = 0.5 // 轨迹终点与提交位置不符
+)
+
+// 轨迹最少采样点数
+const minTrackPoints = 5
+
+// 解析前端提交的轨迹，格式为 [[t,x,y],...]
+func parseTrack(s string) ([]trackPoint, error) {
+	var raw [][]float64
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, err
+	}
+
+	points := make([]trackPoint, 0, len(raw))
+	for _, p := range raw {
+		if len(p) != 3 {
+			return nil, errors.New("轨迹采样点格式应为 [t,x,y]")
+		}
+		points = append(points, trackPoint{T: p[0], X: p[1], Y: p[2]})
+	}
+	return points, nil
+}
+
+// 分析轨迹，返回风险分值（0~1，越高越像机器）以及命中的特征
+// finalX 小于0时不比较终点，tolerance 为终点允许的误差（像素）
+func analyzeTrack(points []trackPoint, finalX, tolerance int) (score float64, reasons []string) {
+	hit := func(risk float64, reason string) {
+		score += risk
+		reasons = append(reasons, reason)
+	}
+	defer func() {
+		score = math.Min(score, 1)
+	}()
+
+	if len(points) < minTrackPoints {
+		hit(riskTooFew, "too few samples")
+		return
+	}
+
+	// 时间必须递增
+	for i := 1; i < len(points); i++ {
+		if points[i].T < points[i-1].T {
+			hit(1, "timestamps not monotonic")
+			return
+		}
+	}
+
+	first, last := points[0], points[len(points)-1]
+
+	duration := last.T - first.T
+	if duration < float64(conf.Track.MinDuration) || duration > float64(conf.Track.MaxDuration) {
+		hit(riskDuration, "implausible duration")
+	}
+
+	if finalX >= 0 && math.Abs(last.X-float64(finalX)) > float64(tolerance) {
+		hit(riskEndMismatch, "track end differs from drop position")
+	}
+
+	// 速度变异系数过小说明匀速拖动
+	var speeds []float64
+	for i := 1; i < len(points); i++ {
+		dt := points[i].T - points[i-1].T
+		if dt <= 0 {
+			continue
+		}
+		speeds = append(speeds, math.Hypot(points[i].X-points[i-1].X, points[i].Y-points[i-1].Y)/dt)
+	}
+	if mean, std := meanStd(speeds); len(speeds) >= 2 && (mean == 0 || std/mean < 0.1) {
+		hit(riskConstSpeed, "constant velocity")
+	}
+
+	// 人手拖动时纵向总会有抖动
+	jitter := false
+	for _, p := range points {
+		if p.Y != first.Y {
+			jitter = true
+			break
+		}
+	}
+	if !jitter {
+		hit(riskNoJitter, "no vertical jitter")
+	}
+
+	// 所有点到首尾连线的距离都很小，说明轨迹是直线
+	dx, dy := last.X-first.X, last.Y-first.Y
+	length := math.Hypot(dx, dy)
+	if length > 0 {
+		maxDist := 0.0
+		for _, p := range points {
+			d := math.Abs(dy*(p.X-first.X)-dx*(p.Y-first.Y)) / length
+			maxDist = math.Max(maxDist, d)
+		}
+		if maxDist < 0.5 {
+			hit(riskLinear, "perfectly linear path")
+		}
+	}
+	return
+}
+
+// 计算平均值与标准差
+func meanStd(values []float64) (mean, std float64) {
+	if len(values) == 0 {
+		return
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		std += (v - mean) * (v - mean)
+	}
+	std = math.Sqrt(std / float64(len(values)))
+	return
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 模拟人手拖动：先快后慢，纵向有不规则抖动
+func humanTrack() []trackPoint {
+	jitter := []float64{0, 1, 1, 2, 1, 0, -1, 0, 1, 3, 2, 2, 1, 0, 0, -1, -2, -1, 0, 1, 1}
+	points := make([]trackPoint, 0, len(jitter))
+	for i := range jitter {
+		p := float64(i) / float64(len(jitter)-1)
+		points = append(points, trackPoint{
+			T: float64(i) * 40,
+			X: math.Round(200 * (1 - (1-p)*(1-p))),
+			Y: 10 + jitter[i],
+		})
+	}
+	return points
+}
+
+func TestAnalyzeTrack(t *testing.T) {
+	old := conf
+	t.Cleanup(func() { conf = old })
+	conf.Track.MinDuration = defaultMinDrag
+	conf.Track.MaxDuration = defaultMaxDrag
+
+	// 匀速拖动，纵向有 ±1 像素抖动
+	constSpeed := make([]trackPoint, 0, 20)
+	for i := 0; i < 20; i++ {
+		constSpeed = append(constSpeed, trackPoint{T: float64(i) * 40, X: float64(i) * 10, Y: 10 + float64(i%2)})
+	}
+
+	// 50 毫秒内完成的拖动
+	fast := humanTrack()
+	for i := range fast {
+		fast[i].T /= 16
+	}
+
+	// 水平直线，没有纵向抖动
+	flat := humanTrack()
+	for i := range flat {
+		flat[i].Y = 10
+	}
+
+	// 斜向直线，纵向有变化但所有点都在一条直线上
+	linear := humanTrack()
+	for i := range linear {
+		linear[i].Y = 10 + linear[i].X/10
+	}
+
+	// 时间倒退
+	backwards := humanTrack()
+	backwards[3].T = 0
+
+	tests := []struct {
+		name   string
+		points []trackPoint
+		finalX int
+		risk   float64
+		reason string // 为空时不应命中任何特征
+	}{
+		{"human", humanTrack(), 200, 0, ""},
+		{"human without end check", humanTrack(), -1, 0, ""},
+		{"constant velocity", constSpeed, 190, riskConstSpeed, "constant velocity"},
+		{"implausible duration", fast, 200, riskDuration, "implausible duration"},
+		{"no vertical jitter", flat, 200, 1, "no vertical jitter"},
+		{"perfectly linear", linear, 200, riskLinear, "perfectly linear path"},
+		{"end mismatch", humanTrack(), 150, riskEndMismatch, "track end differs from drop position"},
+		{"end mismatch and fast", fast, 150, 1, "track end differs from drop position"},
+		{"too few samples", humanTrack()[:3], 200, riskTooFew, "too few samples"},
+		{"timestamps not monotonic", backwards, 200, 1, "timestamps not monotonic"},
+	}
+	for _, tt := range tests {
+		score, reasons := analyzeTrack(tt.points, tt.finalX, defaultTolerance)
+		if math.Abs(score-tt.risk) > 1e-9 {
+			t.Errorf("%s: score = %v, reasons = %v, want %v", tt.name, score, reasons, tt.risk)
+		}
+		if len(tt.reason) == 0 {
+			if len(reasons) > 0 {
+				t.Errorf("%s: reasons = %v, want none", tt.name, reasons)
+			}
+			continue
+		}
+		found := false
+		for _, r := range reasons {
+			found = found || r == tt.reason
+		}
+		if !found {
+			t.Errorf("%s: reasons = %v, want %q", tt.name, reasons, tt.reason)
+		}
+	}
+
+	// 除终点不符外，每项特征单独命中即达到默认风险阈值
+	for _, risk := range []float64{riskTooFew, riskDuration, riskConstSpeed, riskNoJitter, riskLinear} {
+		if risk < defaultRisk {
+			t.Errorf("single heuristic risk %v below default threshold %v", risk, defaultRisk)
+		}
+	}
+}
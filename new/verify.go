@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 校验滑块最终位置
+func verify(c *gin.Context) {
+
+	// 获取签名，兼容前端直接回传 getCode 中已转义的 sign
+	sign := c.PostForm("sign")
+	if strings.Contains(sign, "%") {
+		if s, err := url.QueryUnescape(sign); err == nil {
+			sign = s
+		}
+	}
+
+	slider, err := decodeSliderInfo(sign)
+	if len(slider.Id) > 0 {
+		setLogSlider(c, slider)
+	}
+	if err != nil {
+		verifyResults.WithLabelValues(verifyOutcome(err), slider.site().key).Inc()
+		responseSignError(c, err, "请求参数sign签名不正确")
+		return
+	}
+
+	ct, ok := challengeTypes[slider.challengeType()]
+	if !ok {
+		responseJson(c, statusFail, nil, "不支持的验证码类型")
+		return
+	}
+	if !checkOrigin(c, slider.site()) {
+		responseJson(c, statusFail, nil, "该来源不允许访问")
+		return
+	}
+
+	// 按类型校验答案
+	result, err := ct.check(c, slider)
+	if err != nil {
+		verifyResults.WithLabelValues(verifyInvalid, slider.site().key).Inc()
+		responseJson(c, statusFail, nil, err.Error())
+		return
+	}
+	risk := result.risk
+	if result.bot {
+		risk = 1
+	}
+	pass := result.pass && risk < conf.Track.RiskThreshold
+
+	// 记录验证次数，次数用尽后的请求不再判定，防止并发猜测
+	attempts, err := store.Attempt(slider.Id, conf.Store.MaxAttempts)
+	if err != nil {
+		verifyResults.WithLabelValues(verifyOutcome(err), slider.site().key).Inc()
+		responseSignError(c, err, "数据错误")
+		return
+	}
+
+	// 验证通过或次数用尽后作废
+	if pass || result.bot || attempts >= conf.Store.MaxAttempts {
+		if err := store.Consume(slider.Id); err != nil {
+			verifyResults.WithLabelValues(verifyOutcome(err), slider.site().key).Inc()
+			responseSignError(c, err, "数据错误")
+			return
+		}
+	}
+	if pass {
+		verifyResults.WithLabelValues(verifyPass, slider.site().key).Inc()
+	} else {
+		verifyResults.WithLabelValues(verifyFail, slider.site().key).Inc()
+	}
+	sliderLog(c, slider).WithFields(logrus.Fields{"pass": pass, "risk": risk, "attempts": attempts}).Info("验证结果")
+
+	res := map[string]interface{}{
+		"pass": pass,
+		"risk": math.Round(risk*100) / 100,
+	}
+	if !pass {
+		responseJson(c, statusFail, res, "验证失败")
+		return
+	}
+
+	// 签发验证通过凭证，由前端提交给业务后台
+	token, err := newChallengeID()
+	if err != nil {
+		responseJson(c, statusFail, nil, "数据错误")
+		return
+	}
+	err = store.SavePass(token, PassInfo{
+		Id:   slider.Id,
+		Type: slider.challengeType(),
+		Ip:   c.ClientIP(),
+		Time: time.Now().Unix(),
+		Site: slider.site().key,
+	}, time.Duration(conf.Section.PassTimeout)*time.Second)
+	if err != nil {
+		sliderLog(c, slider).WithError(err).Error("验证通过凭证保存失败")
+		responseJson(c, statusFail, nil, "数据错误")
+		return
+	}
+	res["token"] = token
+
+	responseJson(c, statusSuccess, res, "验证成功")
+}
+
+// 业务后台核验验证通过凭证
+func siteVerify(c *gin.Context) {
+
+	// 按密钥确定站点，凭证只能由签发站点的后台核验
+	st, ok := siteBySecret(c.PostForm("secret"))
+	if !ok {
+		responseJson(c, statusFail, map[string]bool{"success": false}, "密钥不正确")
+		return
+	}
+
+	pass, err := store.TakePass(c.PostForm("token"))
+	if err != nil {
+		if err != errNoPass {
+			reqLog(c).WithError(err).WithField("site", st.key).Error("验证通过凭证读取失败")
+		}
+		responseJson(c, statusFail, map[string]bool{"success": false}, "凭证无效或已使用")
+		return
+	}
+	if pass.Site != st.key && !(len(pass.Site) == 0 && st.key == defaultSiteKey) {
+		responseJson(c, statusFail, map[string]bool{"success": false}, "凭证不属于该站点")
+		return
+	}
+
+	responseJson(c, statusSuccess, map[string]interface{}{
+		"success":   true,
+		"timestamp": pass.Time,
+		"ip":        pass.Ip,
+		"type":      pass.Type,
+		"site":      st.key,
+	}, "核验成功")
+}
+
+// 取绝对值
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// 分析前端提交的拖动轨迹，返回风险分值，finalX 小于0时不比较终点
+func trackRisk(c *gin.Context, slider SliderInfo, finalX int) (float64, error) {
+	rt := c.PostForm("track")
+	if len(rt) == 0 {
+		if conf.Track.Require {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	points, err := parseTrack(rt)
+	if err != nil {
+		return 0, errors.New("请求参数track不正确")
+	}
+	risk, reasons := analyzeTrack(points, finalX, slider.site().tolerance)
+	if len(reasons) > 0 {
+		sliderLog(c, slider).WithFields(logrus.Fields{"ip": c.ClientIP(), "reasons": reasons}).Warn("轨迹异常")
+	}
+	return risk, nil
+}